@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sent-hil/pg_struct_parser/diff"
+)
+
+func runCheck(args []string) error {
+	fs := newFlagSet("check", "base.sql head.sql")
+	format := fs.String("format", "text", "output format: text, or json for a machine-readable report")
+	output := fs.String("o", "-", "output `file`, or - for stdout")
+	backend := addBackendFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two input files, got %d", fs.NArg())
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	base, err := loadSchema(fs.Arg(0), *backend)
+	if err != nil {
+		return err
+	}
+	head, err := loadSchema(fs.Arg(1), *backend)
+	if err != nil {
+		return err
+	}
+
+	report := checkReport{Breaking: []finding{}}
+	for _, b := range diff.Compare(base, head).Breaking() {
+		// Point at the object in head when it is still there
+		f := finding{File: inputName(fs.Arg(1)), Object: string(b.Change.Object), Name: b.Change.Path(), Message: b.Message}
+		pos := b.Change.NewPos
+		if !pos.IsValid() {
+			f.File, pos = inputName(fs.Arg(0)), b.Change.OldPos
+		}
+		f.Line, f.Column = pos.Line, pos.Column
+		report.Breaking = append(report.Breaking, f)
+	}
+
+	err = writeOutput(*output, func(w io.Writer) error {
+		if *format == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		for _, f := range report.Breaking {
+			if f.Line > 0 {
+				fmt.Fprintf(w, "%s:%d:%d: %s\n", f.File, f.Line, f.Column, f.Message)
+			} else {
+				fmt.Fprintf(w, "%s: %s\n", f.File, f.Message)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(report.Breaking) > 0 {
+		fmt.Fprintf(os.Stderr, "Found %d breaking changes\n", len(report.Breaking))
+		return errFailed
+	}
+	return nil
+}
+
+// checkReport is the JSON document check writes.
+type checkReport struct {
+	Breaking []finding `json:"breaking"`
+}
+
+// finding is a breaking change located in one of the two dumps.
+type finding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Object  string `json:"object"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sent-hil/pg_struct_parser/diff"
+)
+
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", "old.sql new.sql")
+	format := fs.String("format", "text", "output format: text, json, or sql for a migration from the old schema to the new one")
+	output := fs.String("o", "-", "output `file`, or - for stdout")
+	backend := addBackendFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two input files, got %d", fs.NArg())
+	}
+	if *format != "text" && *format != "json" && *format != "sql" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	oldSchema, err := loadSchema(fs.Arg(0), *backend)
+	if err != nil {
+		return err
+	}
+	newSchema, err := loadSchema(fs.Arg(1), *backend)
+	if err != nil {
+		return err
+	}
+
+	d := diff.Compare(oldSchema, newSchema)
+	var warnings []diff.Warning
+	err = writeOutput(*output, func(w io.Writer) error {
+		switch *format {
+		case "json":
+			return d.WriteJSON(w)
+		case "sql":
+			warnings, err = d.WriteMigration(w)
+			return err
+		}
+		return d.WriteText(w)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if len(warnings) > 0 {
+		return errFailed
+	}
+	return nil
+}
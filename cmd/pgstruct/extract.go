@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sent-hil/pg_struct_parser/extract"
+	"github.com/sent-hil/pg_struct_parser/visualizer"
+)
+
+func runExtract(args []string) error {
+	fs := newFlagSet("extract", "[structure.sql]")
+	var opts extract.Options
+	selection := addSelectionFlags(fs)
+	fs.Var((*listFlag)(&opts.Whitelist), "whitelist", "select the tables matching `patterns` and always include them in full (repeatable, comma-separated)")
+	fs.IntVar(&opts.Depth, "depth", 0, "include the tables up to `n` foreign key hops away in full; -1 follows foreign keys until no new tables are found")
+	fs.BoolVar(&opts.Inward, "inward", false, "with -depth, also follow foreign keys that point into the selection")
+	fs.BoolVar(&opts.Related, "related", false, "add stubs of the tables that reference, or are referenced by, the selected tables")
+	stubs := fs.String("stubs", "referenced", "what stubs keep besides the primary key: referenced (columns used by foreign keys), pk (nothing) or full (every column)")
+	fs.BoolVar(&opts.GuessRelationships, "guess-relationships", false, "with -related, also follow <name>_id columns that have no foreign key")
+	output := fs.String("o", "-", "output `file`, or - for stdout")
+	format := fs.String("format", "sql", "output format: sql, or json for the schema visualizer")
+	deparse := fs.Bool("deparse", false, "regenerate each statement from its parse tree instead of copying the dump's text")
+	backend := addBackendFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	input, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+	opts.Prefix = selection.prefix
+	opts.Exclude = selection.exclude
+	if opts.Include, err = selection.patterns(); err != nil {
+		return err
+	}
+	if opts.Prefix == "" && len(opts.Include) == 0 && len(opts.Whitelist) == 0 {
+		fs.Usage()
+		return fmt.Errorf("-prefix, -include, -tables-from or -whitelist is required")
+	}
+	if *format != "sql" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	switch *stubs {
+	case "referenced":
+		opts.Stubs = extract.StubReferenced
+	case "pk":
+		opts.Stubs = extract.StubPrimaryKey
+	case "full":
+		opts.Stubs = extract.StubFull
+	default:
+		return fmt.Errorf("unknown stub level %q", *stubs)
+	}
+
+	s, err := loadSchema(input, *backend)
+	if err != nil {
+		return err
+	}
+
+	extracted, err := extract.Extract(s, opts)
+	if err != nil {
+		return err
+	}
+	if *deparse {
+		if *backend != "pg_query" {
+			return fmt.Errorf("-deparse requires the pg_query backend")
+		}
+		if extracted, err = extracted.Deparsed(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d total tables\n", len(s.Tables))
+	types := len(extracted.Enums) + len(extracted.Domains) + len(extracted.CompositeTypes) + len(extracted.RangeTypes)
+	fmt.Fprintf(os.Stderr, "Extracted %d tables, %d views, %d functions, %d triggers, %d types, %d sequences, %d indexes and %d foreign key constraints\n",
+		len(extracted.Tables), len(extracted.Views), len(extracted.Functions), len(extracted.Triggers), types, len(extracted.Sequences), len(extracted.Indexes), len(extracted.ForeignKeys))
+
+	return writeOutput(*output, func(w io.Writer) error {
+		if *format == "json" {
+			return visualizer.WriteJSON(w, extracted)
+		}
+		return extracted.WriteSQL(w)
+	})
+}
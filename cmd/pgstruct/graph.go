@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+func runGraph(args []string) error {
+	fs := newFlagSet("graph", "[structure.sql]")
+	format := fs.String("format", "text", "output format: text or dot")
+	selection := addSelectionFlags(fs)
+	guess := fs.Bool("guess-relationships", false, "add relationships guessed from <name>_id columns without a foreign key")
+	output := fs.String("o", "-", "output `file`, or - for stdout")
+	backend := addBackendFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	input, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+
+	s, err := loadSchema(input, *backend)
+	if err != nil {
+		return err
+	}
+
+	graph := schema.NewGraph(s, *guess)
+	tables := s.Tables
+	if selection.isSet() {
+		sel, err := selection.selector()
+		if err != nil {
+			return err
+		}
+		tables = s.Filter(sel).Tables
+		graph = touching(graph, tables)
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		switch *format {
+		case "text":
+			for _, rel := range graph.Relationships {
+				fmt.Fprintln(w, formatRelationship(rel))
+			}
+			for _, dep := range graph.Dependencies {
+				to := dep.To
+				if len(dep.Columns) > 0 {
+					to += "(" + strings.Join(dep.Columns, ", ") + ")"
+				}
+				fmt.Fprintf(w, "%s -> %s\t%s\n", dep.From, to, dep.Kind)
+			}
+		case "dot":
+			fmt.Fprintln(w, "digraph schema {")
+			fmt.Fprintln(w, "  node [shape=box];")
+			for _, table := range tables {
+				fmt.Fprintf(w, "  %q;\n", table.QualifiedName())
+			}
+			for _, rel := range graph.Relationships {
+				style := "solid"
+				if rel.Source == schema.Guessed {
+					style = "dashed"
+				}
+				label := strings.Join(rel.Columns, ", ")
+				if rel.Options != "" {
+					label += "\n" + rel.Options
+				}
+				fmt.Fprintf(w, "  %q -> %q [label=%q, style=%s];\n", rel.From, rel.To, label, style)
+			}
+			// Tables, sequences and types are boxes already
+			drawn := make(map[string]bool)
+			for _, dep := range graph.Dependencies {
+				for _, name := range []string{dep.From, dep.To} {
+					if shape := nodeShapes[graph.Kinds[name]]; shape != "" && !drawn[name] {
+						drawn[name] = true
+						fmt.Fprintf(w, "  %q [shape=%s];\n", name, shape)
+					}
+				}
+			}
+			for _, dep := range graph.Dependencies {
+				fmt.Fprintf(w, "  %q -> %q [label=%q, style=dotted];\n", dep.From, dep.To, strings.Join(dep.Columns, ", "))
+			}
+			fmt.Fprintln(w, "}")
+		default:
+			return fmt.Errorf("unknown format %q", *format)
+		}
+		return nil
+	})
+}
+
+// nodeShapes are the dot shapes of the objects dependencies start from,
+// other than tables.
+var nodeShapes = map[schema.ObjectKind]string{
+	schema.ViewObject:             "ellipse",
+	schema.MaterializedViewObject: "ellipse",
+	schema.FunctionObject:         "hexagon",
+}
+
+// touching returns the relationships of g that start or end at one of
+// tables, and the dependencies of tables, of the objects that depend on
+// them and of the functions those call.
+func touching(g *schema.Graph, tables []schema.TableDef) *schema.Graph {
+	names := make(map[string]bool)
+	var selected []string
+	for _, table := range tables {
+		names[table.QualifiedName()] = true
+		selected = append(selected, table.QualifiedName())
+	}
+	filtered := &schema.Graph{Kinds: g.Kinds}
+	for _, rel := range g.Relationships {
+		if names[rel.From] || names[rel.To] {
+			filtered.Relationships = append(filtered.Relationships, rel)
+		}
+	}
+	for _, view := range g.Dependents(selected) {
+		names[view] = true
+	}
+	// Add the dependencies of the functions reached until no new function
+	// is found
+	added := make([]bool, len(g.Dependencies))
+	for changed := true; changed; {
+		changed = false
+		for i, dep := range g.Dependencies {
+			if added[i] || !names[dep.From] {
+				continue
+			}
+			added[i] = true
+			changed = true
+			filtered.Dependencies = append(filtered.Dependencies, dep)
+			if g.Kinds[dep.To] == schema.FunctionObject {
+				names[dep.To] = true
+			}
+		}
+	}
+	return filtered
+}
+
+// formatRelationship describes rel as "from(cols) -> to(cols)", followed
+// by the constraint name and options or a note that the relationship was
+// guessed.
+func formatRelationship(rel schema.Relationship) string {
+	label := rel.ForeignKey
+	if rel.Options != "" {
+		label += " " + rel.Options
+	}
+	if rel.Source == schema.Guessed {
+		label = "(guessed)"
+	}
+	return fmt.Sprintf("%s(%s) -> %s(%s)\t%s",
+		rel.From, strings.Join(rel.Columns, ", "),
+		rel.To, strings.Join(rel.RefColumns, ", "),
+		label)
+}
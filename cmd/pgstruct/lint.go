@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+func runLint(args []string) error {
+	fs := newFlagSet("lint", "[structure.sql]")
+	backend := addBackendFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	input, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+
+	s, err := loadSchema(input, *backend)
+	if err != nil {
+		return err
+	}
+
+	problems := lint(s)
+	for _, problem := range problems {
+		if problem.pos.IsValid() {
+			fmt.Printf("%s:%s: %s\n", inputName(input), problem.pos, problem.message)
+		} else {
+			fmt.Printf("%s: %s\n", inputName(input), problem.message)
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Found %d problems\n", len(problems))
+		return errFailed
+	}
+	return nil
+}
+
+type problem struct {
+	pos     schema.Position
+	message string
+}
+
+// lint checks that every foreign key points from and to columns that
+// exist, and that every column type resolves.
+func lint(s *schema.Schema) []problem {
+	var problems []problem
+	for _, ref := range s.UnresolvedTypes {
+		problems = append(problems, problem{ref.Pos, describeUnresolved(ref)})
+	}
+	for _, fk := range s.ForeignKeys {
+		report := func(format string, args ...interface{}) {
+			problems = append(problems, problem{fk.Pos, fmt.Sprintf(format, args...)})
+		}
+		from := s.Table(fk.FromTable())
+		to := s.Table(fk.ToTable())
+		if from == nil {
+			report("foreign key %s is on unknown table %s", fk.Name, fk.FromTable())
+		}
+		if to == nil {
+			report("foreign key %s references unknown table %s", fk.Name, fk.ToTable())
+		}
+		for _, column := range fk.Columns {
+			if from != nil && from.Column(column) == nil {
+				report("foreign key %s uses unknown column %s.%s", fk.Name, fk.FromTable(), column)
+			}
+		}
+		for _, column := range fk.RefColumns {
+			if to != nil && to.Column(column) == nil {
+				report("foreign key %s references unknown column %s.%s", fk.Name, fk.ToTable(), column)
+			}
+		}
+	}
+	return problems
+}
+
+// describeUnresolved reports a type that does not resolve, naming the
+// column or attribute that uses it if there is one.
+func describeUnresolved(ref schema.TypeReference) string {
+	if ref.Column == "" {
+		return fmt.Sprintf("type %s is built on unknown type %s", ref.Object, ref.Type)
+	}
+	return fmt.Sprintf("column %s.%s has unknown type %s", ref.Object, ref.Column, ref.Type)
+}
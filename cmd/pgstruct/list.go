@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+func runList(args []string) error {
+	fs := newFlagSet("list", "[structure.sql]")
+	kind := fs.String("kind", "tables", "objects to list: tables, views, functions, triggers, enums, types, sequences, foreign-keys, indexes or statements")
+	selection := addSelectionFlags(fs)
+	output := fs.String("o", "-", "output `file`, or - for stdout")
+	backend := addBackendFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	input, err := inputArg(fs)
+	if err != nil {
+		return err
+	}
+
+	s, err := loadSchema(input, *backend)
+	if err != nil {
+		return err
+	}
+	if selection.isSet() {
+		sel, err := selection.selector()
+		if err != nil {
+			return err
+		}
+		s = s.Filter(sel)
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		switch *kind {
+		case "tables":
+			for _, table := range s.Tables {
+				fmt.Fprintf(w, "%s\t%d columns\n", table.QualifiedName(), len(table.Columns))
+			}
+		case "views":
+			for _, view := range s.Views {
+				kind := "view"
+				if view.Materialized {
+					kind = "materialized view"
+				}
+				fmt.Fprintf(w, "%s\t%s over %s\n", view.QualifiedName(), kind, strings.Join(view.Relations, ", "))
+			}
+		case "functions":
+			for _, fn := range s.Functions {
+				fmt.Fprintf(w, "%s\t%s\n", fn.Signature(), formatFunction(fn))
+			}
+		case "triggers":
+			for _, trigger := range s.Triggers {
+				fmt.Fprintf(w, "%s\ton %s executes %s\n", trigger.Name, trigger.TableName(), trigger.Function)
+			}
+		case "enums":
+			for _, enum := range s.Enums {
+				fmt.Fprintf(w, "%s\t%s\n", enum.QualifiedName(), strings.Join(enum.Values, ", "))
+			}
+		case "types":
+			for _, domain := range s.Domains {
+				fmt.Fprintf(w, "%s\t%s\n", domain.QualifiedName(), formatDomain(domain))
+			}
+			for _, typ := range s.CompositeTypes {
+				var attributes []string
+				for _, attr := range typ.Attributes {
+					attributes = append(attributes, attr.Name+" "+attr.Type)
+				}
+				fmt.Fprintf(w, "%s\tcomposite (%s)\n", typ.QualifiedName(), strings.Join(attributes, ", "))
+			}
+			for _, rng := range s.RangeTypes {
+				fmt.Fprintf(w, "%s\trange of %s\n", rng.QualifiedName(), rng.Subtype)
+			}
+		case "sequences":
+			for _, seq := range s.Sequences {
+				owner := seq.OwnedBy
+				if owner == "" {
+					owner = "(none)"
+				}
+				fmt.Fprintf(w, "%s\towned by %s\n", seq.QualifiedName(), owner)
+			}
+		case "foreign-keys":
+			for _, fk := range s.ForeignKeys {
+				fmt.Fprintf(w, "%s\t%s\n", fk.Name, formatForeignKey(fk))
+			}
+		case "indexes":
+			for _, index := range s.Indexes {
+				fmt.Fprintf(w, "%s\t%s\n", index.Name, formatIndex(index))
+			}
+		case "statements":
+			for _, stmt := range s.Statements {
+				firstLine := strings.SplitN(stmt.SQL, "\n", 2)[0]
+				fmt.Fprintf(w, "%s\t%s\n", stmt.Pos, firstLine)
+			}
+		default:
+			return fmt.Errorf("unknown kind %q", *kind)
+		}
+		return nil
+	})
+}
+
+// formatForeignKey describes fk as "from.table(cols) -> to.table(cols)",
+// followed by any clauses that differ from the defaults.
+func formatForeignKey(fk schema.ForeignKeyDef) string {
+	text := fmt.Sprintf("%s(%s) -> %s(%s)",
+		fk.FromTable(), strings.Join(fk.Columns, ", "),
+		fk.ToTable(), strings.Join(fk.RefColumns, ", "))
+	if options := fk.Options(); options != "" {
+		text += " " + options
+	}
+	return text
+}
+
+// formatIndex describes index as "[unique ]method on table(keys)", followed
+// by its INCLUDE columns and predicate.
+func formatIndex(index schema.IndexDef) string {
+	var keys []string
+	for _, col := range index.Columns {
+		key := col.Name
+		if key == "" {
+			key = col.Expression
+		}
+		if col.Opclass != "" {
+			key += " " + col.Opclass
+		}
+		if col.Descending {
+			key += " DESC"
+		}
+		keys = append(keys, key)
+	}
+
+	text := fmt.Sprintf("%s on %s(%s)", index.Method, index.TableName(), strings.Join(keys, ", "))
+	if index.Unique {
+		text = "unique " + text
+	}
+	if len(index.Include) > 0 {
+		text += fmt.Sprintf(" include (%s)", strings.Join(index.Include, ", "))
+	}
+	if index.Where != "" {
+		text += " where " + index.Where
+	}
+	return text
+}
+
+// formatDomain describes domain as "domain over type" followed by its
+// default, NOT NULL and CHECK constraints.
+func formatDomain(domain schema.DomainDef) string {
+	parts := []string{"domain over " + domain.Type}
+	if domain.Default != "" {
+		parts = append(parts, "DEFAULT "+domain.Default)
+	}
+	if domain.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	return strings.Join(append(parts, domain.Checks...), " ")
+}
+
+// formatFunction describes fn as its result type and language, followed
+// by the tables, sequences and types it uses.
+func formatFunction(fn schema.FunctionDef) string {
+	var parts []string
+	if fn.ReturnType != "" {
+		parts = append(parts, "returns "+fn.ReturnType)
+	}
+	parts = append(parts, "language "+fn.Language)
+	for _, uses := range []struct {
+		label string
+		names []string
+	}{
+		{"tables", fn.Tables},
+		{"sequences", fn.Sequences},
+		{"types", fn.Types},
+	} {
+		if len(uses.names) > 0 {
+			parts = append(parts, uses.label+" "+strings.Join(uses.names, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
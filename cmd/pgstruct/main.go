@@ -0,0 +1,252 @@
+// Command pgstruct inspects PostgreSQL schema dumps and extracts the tables
+// of a single domain from them.
+//
+// Usage:
+//
+//	pgstruct <command> [flags] [structure.sql]
+//
+// The dump is read from the named file, or from stdin when the file is
+// omitted or "-". Run "pgstruct <command> -h" for the flags of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"extract", "write the tables of one domain as a loadable SQL script", runExtract},
+	{"list", "list the tables, enums or foreign keys in a dump", runList},
+	{"graph", "print the foreign key graph as text or Graphviz dot", runGraph},
+	{"lint", "report problems such as foreign keys to unknown tables", runLint},
+	{"diff", "compare two dumps table by table", runDiff},
+	{"check", "fail when a dump makes backward-incompatible changes to another", runCheck},
+}
+
+// errFailed is returned by commands that have already reported why they
+// failed, so main only needs to set the exit status.
+var errFailed = errors.New("failed")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(os.Args[2:])
+		switch {
+		case err == nil:
+			return
+		case errors.Is(err, flag.ErrHelp):
+			os.Exit(2)
+		case errors.Is(err, errFailed):
+			os.Exit(1)
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if name != "-h" && name != "-help" && name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pgstruct <command> [flags] [structure.sql]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// newFlagSet returns a flag set for a subcommand whose usage message lists
+// its flags.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pgstruct %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parsers maps the -backend flag values to schema parsers.
+var parsers = map[string]func(io.Reader) (*schema.Schema, error){
+	"pg_query": schema.Parse,
+	"regex":    schema.ParseRegex,
+}
+
+func addBackendFlag(fs *flag.FlagSet) *string {
+	return fs.String("backend", "pg_query", "parser to use: pg_query or regex")
+}
+
+// loadSchema parses the dump at path, or stdin when path is empty or "-".
+func loadSchema(path, backend string) (*schema.Schema, error) {
+	parse, ok := parsers[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+
+	in, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	s, err := parse(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", inputName(path), err)
+	}
+	return s, nil
+}
+
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	return file, nil
+}
+
+func inputName(path string) string {
+	if path == "" || path == "-" {
+		return "<stdin>"
+	}
+	return path
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// createOutput creates the file at path, or returns stdout when path is
+// empty or "-".
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %v", err)
+	}
+	return file, nil
+}
+
+// writeOutput calls write with the output at path and closes it, reporting
+// the first error.
+func writeOutput(path string, write func(io.Writer) error) error {
+	out, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// listFlag is a flag that may be repeated or given a comma-separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// inputArg returns the single positional argument naming the dump.
+func inputArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return "", fmt.Errorf("expected at most one input file, got %d", fs.NArg())
+	}
+	return fs.Arg(0), nil
+}
+
+// selectionFlags are the flags that choose the tables a command works on.
+type selectionFlags struct {
+	prefix     string
+	include    listFlag
+	exclude    listFlag
+	tablesFrom string
+}
+
+func addSelectionFlags(fs *flag.FlagSet) *selectionFlags {
+	f := &selectionFlags{}
+	fs.StringVar(&f.prefix, "prefix", "", "select the public tables named `prefix`_*")
+	fs.Var(&f.include, "include", "select the tables matching `patterns`: globs such as billing.* or /regexes/, matched against schema.table (repeatable, comma-separated)")
+	fs.Var(&f.exclude, "exclude", "never select the tables matching `patterns` (repeatable, comma-separated)")
+	fs.StringVar(&f.tablesFrom, "tables-from", "", "select the tables or patterns listed one per line in `file`")
+	return f
+}
+
+// patterns returns the include patterns, other than -prefix, with the ones
+// read from -tables-from.
+func (f *selectionFlags) patterns() ([]string, error) {
+	include := append([]string(nil), f.include...)
+	if f.tablesFrom == "" {
+		return include, nil
+	}
+	data, err := os.ReadFile(f.tablesFrom)
+	if err != nil {
+		return nil, fmt.Errorf("error reading table list: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			include = append(include, line)
+		}
+	}
+	return include, nil
+}
+
+// isSet reports whether any selection flag was given.
+func (f *selectionFlags) isSet() bool {
+	return f.prefix != "" || len(f.include) > 0 || len(f.exclude) > 0 || f.tablesFrom != ""
+}
+
+// selector returns the selector described by the flags. Without include
+// patterns every table not excluded is selected.
+func (f *selectionFlags) selector() (*schema.Selector, error) {
+	include, err := f.patterns()
+	if err != nil {
+		return nil, err
+	}
+	if f.prefix != "" {
+		include = append(include, schema.PrefixPattern(f.prefix))
+	}
+	if len(include) == 0 {
+		include = []string{"*.*"}
+	}
+	return schema.NewSelector(include, f.exclude)
+}
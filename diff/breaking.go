@@ -0,0 +1,231 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+// Breakage is a change that is not backward compatible: code or data that
+// worked with the old schema may fail against the new one.
+type Breakage struct {
+	Change  Change
+	Message string
+}
+
+// Breaking returns the changes in d that break backward compatibility:
+// dropped tables, columns and enum types, columns whose type was narrowed
+// or changed, columns that became NOT NULL without a default, and enum
+// values that were removed. A renamed column shows up as a dropped one.
+// Dropped tables and columns name the views and functions of the old
+// schema that use them and that the new schema keeps.
+func (d *Diff) Breaking() []Breakage {
+	var breakages []Breakage
+	report := func(c Change, format string, args ...interface{}) {
+		breakages = append(breakages, Breakage{c, fmt.Sprintf(format, args...)})
+	}
+
+	graph := schema.NewGraph(d.Old, false)
+	for _, c := range d.Changes {
+		switch {
+		case c.Object == Table && c.Kind == Removed:
+			referencedBy := d.referencingTables(c.Name)
+			for _, dependent := range d.remainingDependents(graph, graph.Dependents([]string{c.Name})) {
+				referencedBy = append(referencedBy, dependent.kind+" "+dependent.name)
+			}
+			if len(referencedBy) > 0 {
+				report(c, "table %s was dropped but is referenced by %s", c.Name, strings.Join(referencedBy, ", "))
+			} else {
+				report(c, "table %s was dropped", c.Name)
+			}
+		case c.Object == Column && c.Kind == Removed:
+			dependents := d.remainingDependents(graph, graph.ColumnDependents(c.Parent, c.Name))
+			if len(dependents) == 0 {
+				report(c, "column %s was dropped or renamed", c.Path())
+			} else {
+				report(c, "column %s was dropped or renamed but is used by %s", c.Path(), describeDependents(dependents))
+			}
+		case c.Object == Column && c.Kind == Added:
+			col := d.New.Table(c.Parent).Column(c.Name)
+			if col.IsNotNull && col.Default == "" {
+				report(c, "column %s was added as NOT NULL without a default", c.Path())
+			}
+		case c.Object == Column && c.Field == "type":
+			if narrowed, ok := narrows(c.Old, c.New); ok && narrowed {
+				report(c, "column %s was narrowed from %s to %s", c.Path(), c.Old, c.New)
+			} else if !ok {
+				report(c, "column %s changed type from %s to %s", c.Path(), c.Old, c.New)
+			}
+		case c.Object == Column && c.Field == "nullability" && c.New == "NOT NULL":
+			if d.New.Table(c.Parent).Column(c.Name).Default == "" {
+				report(c, "column %s became NOT NULL without a default", c.Path())
+			}
+		case c.Object == Enum && c.Kind == Removed:
+			report(c, "enum type %s was dropped", c.Name)
+		case c.Object == EnumValue && c.Kind == Removed:
+			report(c, "value %q was removed from enum type %s", c.Name, c.Parent)
+		}
+	}
+	return breakages
+}
+
+// dependent is a view or function that uses a dropped table or column.
+type dependent struct {
+	kind string
+	name string
+}
+
+// remainingDependents returns the views and functions of the old schema,
+// out of names, that the new schema still defines.
+func (d *Diff) remainingDependents(graph *schema.Graph, names []string) []dependent {
+	var remaining []dependent
+	for _, name := range names {
+		switch graph.Kinds[name] {
+		case schema.ViewObject, schema.MaterializedViewObject:
+			if d.New.View(name) != nil {
+				remaining = append(remaining, dependent{"view", name})
+			}
+		case schema.FunctionObject:
+			if d.New.Function(name) != nil {
+				remaining = append(remaining, dependent{"function", name})
+			}
+		}
+	}
+	return remaining
+}
+
+// describeDependents lists dependents by kind, such as
+// "views a, b and function f(integer)".
+func describeDependents(dependents []dependent) string {
+	var parts []string
+	for _, kind := range []string{"view", "function"} {
+		var names []string
+		for _, dep := range dependents {
+			if dep.kind == kind {
+				names = append(names, dep.name)
+			}
+		}
+		switch len(names) {
+		case 0:
+		case 1:
+			parts = append(parts, kind+" "+names[0])
+		default:
+			parts = append(parts, kind+"s "+strings.Join(names, ", "))
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+// referencingTables returns the tables of the new schema that the old
+// schema's foreign keys pointed from into table.
+func (d *Diff) referencingTables(table string) []string {
+	var tables []string
+	for _, fk := range d.Old.ForeignKeys {
+		if fk.ToTable() == table && fk.FromTable() != table && d.New.Table(fk.FromTable()) != nil && !contains(tables, fk.FromTable()) {
+			tables = append(tables, fk.FromTable())
+		}
+	}
+	return tables
+}
+
+var typeModifiers = regexp.MustCompile(`^(.*?)\s*\(([\d,\s]+)\)(.*)$`)
+
+// widenings lists, for each base type, the types every one of its values
+// converts to unchanged.
+var widenings = map[string][]string{
+	"smallint":          {"integer", "bigint", "numeric"},
+	"integer":           {"bigint", "numeric"},
+	"bigint":            {"numeric"},
+	"real":              {"double precision"},
+	"character varying": {"text"},
+	"character":         {"character varying", "text"},
+}
+
+// integerDigits gives the number of decimal digits the values of each
+// integer type can have.
+var integerDigits = map[string]int{
+	"smallint": 5,
+	"integer":  10,
+	"bigint":   19,
+}
+
+// fitsWidened reports whether every value of the type from, with modifiers
+// fromMods, fits the type to, one of its widenings, with modifiers toMods.
+func fitsWidened(from string, fromMods []int, to string, toMods []int) bool {
+	if len(toMods) == 0 {
+		return true
+	}
+	switch to {
+	case "numeric":
+		// numeric(p, s) holds p-s digits before the point
+		digits := toMods[0]
+		if len(toMods) == 2 {
+			digits -= toMods[1]
+		}
+		return digits >= integerDigits[from]
+	case "character varying":
+		// character without a length is character(1)
+		length := 1
+		if len(fromMods) > 0 {
+			length = fromMods[0]
+		}
+		return toMods[0] >= length
+	}
+	return true
+}
+
+// splitType splits a type such as "character varying(255)[]" into its base
+// type "character varying[]" and its modifiers [255].
+func splitType(typ string) (base string, modifiers []int) {
+	base = typ
+	if match := typeModifiers.FindStringSubmatch(base); match != nil {
+		base = match[1] + match[3]
+		for _, mod := range strings.Split(match[2], ",") {
+			n, _ := strconv.Atoi(strings.TrimSpace(mod))
+			modifiers = append(modifiers, n)
+		}
+	}
+	array := strings.HasSuffix(base, "[]")
+	base = schema.CanonicalTypeName(strings.TrimSuffix(base, "[]"))
+	if array {
+		base += "[]"
+	}
+	return base, modifiers
+}
+
+// narrows compares two column types. ok is false when the types are
+// unrelated; otherwise narrowed reports whether some values of type from
+// no longer fit type to.
+func narrows(from, to string) (narrowed, ok bool) {
+	oldBase, oldMods := splitType(from)
+	newBase, newMods := splitType(to)
+	if oldBase != newBase {
+		for _, wider := range widenings[strings.TrimSuffix(oldBase, "[]")] {
+			if wider == strings.TrimSuffix(newBase, "[]") && strings.HasSuffix(oldBase, "[]") == strings.HasSuffix(newBase, "[]") {
+				return !fitsWidened(strings.TrimSuffix(oldBase, "[]"), oldMods, wider, newMods), true
+			}
+		}
+		return false, false
+	}
+
+	// Dropping the modifiers removes the limit, adding them sets one
+	if len(newMods) == 0 {
+		return false, true
+	}
+	if len(oldMods) == 0 || len(oldMods) != len(newMods) {
+		return true, true
+	}
+	if newBase == "numeric" && len(newMods) == 2 {
+		// numeric(p, s) holds p-s digits before the point and s after
+		return newMods[0]-newMods[1] < oldMods[0]-oldMods[1] || newMods[1] < oldMods[1], true
+	}
+	for i := range oldMods {
+		if newMods[i] < oldMods[i] {
+			return true, true
+		}
+	}
+	return false, true
+}
@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+// parseDump parses a dump with the regex backend, which needs no cgo.
+func parseDump(t *testing.T, sql string) *schema.Schema {
+	t.Helper()
+	s, err := schema.ParseRegex(strings.NewReader(sql))
+	if err != nil {
+		t.Fatalf("ParseRegex() error = %v", err)
+	}
+	return s
+}
+
+func TestNarrows(t *testing.T) {
+	tests := []struct {
+		old, new string
+		narrowed bool
+		ok       bool
+	}{
+		{"character varying(255)", "character varying(100)", true, true},
+		{"character varying(100)", "character varying(255)", false, true},
+		{"character varying(255)", "character varying", false, true},
+		{"character varying", "character varying(255)", true, true},
+		{"character varying(255)", "text", false, true},
+		{"varchar(20)", "character varying(20)", false, true},
+		{"integer", "bigint", false, true},
+		{"int4", "int8", false, true},
+		{"bigint", "integer", false, false},
+		{"integer[]", "bigint[]", false, true},
+		{"integer[]", "bigint", false, false},
+		{"integer", "numeric", false, true},
+		{"integer", "numeric(3,0)", true, true},
+		{"integer", "numeric(12,2)", false, true},
+		{"bigint", "numeric(12)", true, true},
+		{"character(10)", "character varying(5)", true, true},
+		{"character(10)", "character varying(10)", false, true},
+		{"numeric(10,2)", "numeric(12,2)", false, true},
+		{"numeric(10,2)", "numeric(10,3)", true, true},
+		{"numeric(10,2)", "numeric(11,1)", true, true},
+		{"timestamp(6) without time zone", "timestamp(3) without time zone", true, true},
+		{"text", "jsonb", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.old+" to "+tt.new, func(t *testing.T) {
+			narrowed, ok := narrows(tt.old, tt.new)
+			if narrowed != tt.narrowed || ok != tt.ok {
+				t.Errorf("narrows(%q, %q) = %v, %v, want %v, %v", tt.old, tt.new, narrowed, ok, tt.narrowed, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBreaking(t *testing.T) {
+	const users = `CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email character varying(255),
+    name text
+);
+`
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{
+			name: "no changes",
+			old:  users,
+			new:  users,
+		},
+		{
+			name: "widened column",
+			old:  users,
+			new:  strings.Replace(users, "character varying(255)", "text", 1),
+		},
+		{
+			name: "narrowed column",
+			old:  users,
+			new:  strings.Replace(users, "(255)", "(100)", 1),
+			want: []string{"column public.users.email was narrowed from character varying(255) to character varying(100)"},
+		},
+		{
+			name: "changed type",
+			old:  users,
+			new:  strings.Replace(users, "name text", "name jsonb", 1),
+			want: []string{"column public.users.name changed type from text to jsonb"},
+		},
+		{
+			name: "dropped column",
+			old:  users,
+			new:  strings.Replace(users, ",\n    name text", "", 1),
+			want: []string{"column public.users.name was dropped or renamed"},
+		},
+		{
+			name: "dropped table",
+			old:  users,
+			new:  "",
+			want: []string{"table public.users was dropped"},
+		},
+		{
+			name: "added NOT NULL column",
+			old:  users,
+			new:  strings.Replace(users, "name text", "name text,\n    age integer NOT NULL", 1),
+			want: []string{"column public.users.age was added as NOT NULL without a default"},
+		},
+		{
+			name: "added NOT NULL column with a default",
+			old:  users,
+			new:  strings.Replace(users, "name text", "name text,\n    age integer DEFAULT 0 NOT NULL", 1),
+		},
+		{
+			name: "column became NOT NULL",
+			old:  users,
+			new:  strings.Replace(users, "name text", "name text NOT NULL", 1),
+			want: []string{"column public.users.name became NOT NULL without a default"},
+		},
+		{
+			name: "removed enum value",
+			old:  "CREATE TYPE public.status AS ENUM (\n    'active',\n    'archived'\n);\n",
+			new:  "CREATE TYPE public.status AS ENUM (\n    'active'\n);\n",
+			want: []string{`value "archived" was removed from enum type public.status`},
+		},
+		{
+			name: "added enum value",
+			old:  "CREATE TYPE public.status AS ENUM (\n    'active'\n);\n",
+			new:  "CREATE TYPE public.status AS ENUM (\n    'active',\n    'archived'\n);\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range Compare(parseDump(t, tt.old), parseDump(t, tt.new)).Breaking() {
+				got = append(got, b.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Breaking() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
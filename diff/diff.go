@@ -0,0 +1,266 @@
+// Package diff compares two parsed schemas, such as the structure.sql of a
+// branch and of its base, and reports the tables, columns, constraints,
+// enum types and foreign keys that were added, removed or changed.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+// Kind says what happened to an object.
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Object is the kind of object a change is about.
+type Object string
+
+const (
+	Table      Object = "table"
+	Column     Object = "column"
+	Constraint Object = "constraint"
+	Enum       Object = "enum"
+	EnumValue  Object = "enum value"
+	ForeignKey Object = "foreign key"
+)
+
+// Change is a single difference between two schemas.
+type Change struct {
+	Kind   Kind   `json:"kind"`
+	Object Object `json:"object"`
+	// Parent is the qualified name of the table or enum type that holds
+	// a column, constraint, foreign key or enum value. It is empty for
+	// tables and enum types.
+	Parent string `json:"parent,omitempty"`
+	// Name is the qualified name of a table or enum type, the name of a
+	// column, foreign key or enum value, or the definition of a
+	// constraint.
+	Name string `json:"name"`
+	// Field is the property of a changed object that differs, such as
+	// "type" or "default" for a column.
+	Field string `json:"field,omitempty"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+	// OldPos and NewPos locate the object in the old and new dump. The
+	// position on the side the object is missing from is not valid.
+	OldPos schema.Position `json:"-"`
+	NewPos schema.Position `json:"-"`
+}
+
+// Path returns the name of the changed object qualified by its parent.
+func (c Change) Path() string {
+	if c.Parent == "" {
+		return c.Name
+	}
+	if c.Object == Constraint {
+		return fmt.Sprintf("%s %s", c.Parent, c.Name)
+	}
+	return c.Parent + "." + c.Name
+}
+
+// String describes the change on one line, such as
+// "~ column public.users.email: type varchar(255) -> text".
+func (c Change) String() string {
+	marker := map[Kind]string{Added: "+", Removed: "-", Changed: "~"}[c.Kind]
+	line := fmt.Sprintf("%s %s %s", marker, c.Object, c.Path())
+	if c.Field != "" {
+		line += fmt.Sprintf(": %s %s -> %s", c.Field, describe(c.Old), describe(c.New))
+	}
+	return line
+}
+
+func describe(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// Diff is the result of comparing an old and a new schema.
+type Diff struct {
+	Old     *schema.Schema `json:"-"`
+	New     *schema.Schema `json:"-"`
+	Changes []Change       `json:"changes"`
+}
+
+// Compare returns the changes that turn oldSchema into newSchema. Tables
+// and enum types are matched by qualified name, columns and enum values by
+// name, foreign keys by table and constraint name, and constraints by their
+// definition. Changes are listed table by table in name order, followed by
+// the enum types.
+func Compare(oldSchema, newSchema *schema.Schema) *Diff {
+	d := &Diff{Old: oldSchema, New: newSchema, Changes: []Change{}}
+	oldSchema, newSchema = oldSchema.Sorted(), newSchema.Sorted()
+
+	for _, table := range oldSchema.Tables {
+		if newTable := newSchema.Table(table.QualifiedName()); newTable != nil {
+			d.compareTables(table, *newTable)
+		} else {
+			d.add(Change{Kind: Removed, Object: Table, Name: table.QualifiedName(), OldPos: table.Pos})
+		}
+	}
+	for _, table := range newSchema.Tables {
+		if oldSchema.Table(table.QualifiedName()) == nil {
+			d.add(Change{Kind: Added, Object: Table, Name: table.QualifiedName(), NewPos: table.Pos})
+		}
+	}
+
+	for _, fk := range oldSchema.ForeignKeys {
+		if newFK := findForeignKey(newSchema, fk.FromTable(), fk.Name); newFK == nil {
+			d.add(Change{Kind: Removed, Object: ForeignKey, Parent: fk.FromTable(), Name: fk.Name, OldPos: fk.Pos})
+		} else if formatForeignKey(fk) != formatForeignKey(*newFK) {
+			d.add(Change{Kind: Changed, Object: ForeignKey, Parent: fk.FromTable(), Name: fk.Name,
+				Field: "definition", Old: formatForeignKey(fk), New: formatForeignKey(*newFK),
+				OldPos: fk.Pos, NewPos: newFK.Pos})
+		}
+	}
+	for _, fk := range newSchema.ForeignKeys {
+		if findForeignKey(oldSchema, fk.FromTable(), fk.Name) == nil {
+			d.add(Change{Kind: Added, Object: ForeignKey, Parent: fk.FromTable(), Name: fk.Name, NewPos: fk.Pos})
+		}
+	}
+
+	for _, enum := range oldSchema.Enums {
+		if newEnum := newSchema.Enum(enum.QualifiedName()); newEnum != nil {
+			d.compareEnums(enum, *newEnum)
+		} else {
+			d.add(Change{Kind: Removed, Object: Enum, Name: enum.QualifiedName(), OldPos: enum.Pos})
+		}
+	}
+	for _, enum := range newSchema.Enums {
+		if oldSchema.Enum(enum.QualifiedName()) == nil {
+			d.add(Change{Kind: Added, Object: Enum, Name: enum.QualifiedName(), NewPos: enum.Pos})
+		}
+	}
+	return d
+}
+
+func (d *Diff) add(c Change) {
+	d.Changes = append(d.Changes, c)
+}
+
+func (d *Diff) compareTables(oldTable, newTable schema.TableDef) {
+	name := oldTable.QualifiedName()
+	for _, col := range oldTable.Columns {
+		newCol := newTable.Column(col.Name)
+		if newCol == nil {
+			d.add(Change{Kind: Removed, Object: Column, Parent: name, Name: col.Name, OldPos: col.Pos})
+			continue
+		}
+		changed := func(field, oldValue, newValue string) {
+			if oldValue != newValue {
+				d.add(Change{Kind: Changed, Object: Column, Parent: name, Name: col.Name,
+					Field: field, Old: oldValue, New: newValue, OldPos: col.Pos, NewPos: newCol.Pos})
+			}
+		}
+		changed("type", col.Type, newCol.Type)
+		changed("nullability", nullability(col), nullability(*newCol))
+		changed("default", col.Default, newCol.Default)
+	}
+	for _, col := range newTable.Columns {
+		if oldTable.Column(col.Name) == nil {
+			d.add(Change{Kind: Added, Object: Column, Parent: name, Name: col.Name, NewPos: col.Pos})
+		}
+	}
+
+	oldKey, newKey := strings.Join(oldTable.PrimaryKey, ", "), strings.Join(newTable.PrimaryKey, ", ")
+	if oldKey != newKey {
+		d.add(Change{Kind: Changed, Object: Table, Name: name, Field: "primary key",
+			Old: oldKey, New: newKey, OldPos: oldTable.Pos, NewPos: newTable.Pos})
+	}
+
+	for _, constraint := range oldTable.Constraints {
+		if !contains(newTable.Constraints, constraint) {
+			d.add(Change{Kind: Removed, Object: Constraint, Parent: name, Name: constraint, OldPos: oldTable.Pos})
+		}
+	}
+	for _, constraint := range newTable.Constraints {
+		if !contains(oldTable.Constraints, constraint) {
+			d.add(Change{Kind: Added, Object: Constraint, Parent: name, Name: constraint, NewPos: newTable.Pos})
+		}
+	}
+}
+
+func (d *Diff) compareEnums(oldEnum, newEnum schema.EnumDef) {
+	name := oldEnum.QualifiedName()
+	for _, value := range oldEnum.Values {
+		if !contains(newEnum.Values, value) {
+			d.add(Change{Kind: Removed, Object: EnumValue, Parent: name, Name: value, OldPos: oldEnum.Pos, NewPos: newEnum.Pos})
+		}
+	}
+	for _, value := range newEnum.Values {
+		if !contains(oldEnum.Values, value) {
+			d.add(Change{Kind: Added, Object: EnumValue, Parent: name, Name: value, OldPos: oldEnum.Pos, NewPos: newEnum.Pos})
+		}
+	}
+}
+
+// nullability describes whether col accepts NULL.
+func nullability(col schema.ColumnDef) string {
+	if col.IsNotNull {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+// findForeignKey returns the foreign key of s with the given name on the
+// table with the given qualified name, or nil.
+func findForeignKey(s *schema.Schema, table, name string) *schema.ForeignKeyDef {
+	for i := range s.ForeignKeys {
+		if s.ForeignKeys[i].FromTable() == table && s.ForeignKeys[i].Name == name {
+			return &s.ForeignKeys[i]
+		}
+	}
+	return nil
+}
+
+// formatForeignKey describes fk as "(cols) REFERENCES schema.table(cols)",
+// followed by any clauses that differ from the defaults.
+func formatForeignKey(fk schema.ForeignKeyDef) string {
+	text := fmt.Sprintf("(%s) REFERENCES %s(%s)",
+		strings.Join(fk.Columns, ", "), fk.ToTable(), strings.Join(fk.RefColumns, ", "))
+	if options := fk.Options(); options != "" {
+		text += " " + options
+	}
+	return text
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty reports whether the schemas are the same.
+func (d *Diff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// WriteText writes one line per change, as formatted by Change.String.
+func (d *Diff) WriteText(w io.Writer) error {
+	for _, c := range d.Changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the changes as an indented JSON document.
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
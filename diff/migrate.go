@@ -0,0 +1,324 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+// Warning is a change that Migration could not express safely. The
+// statement for it is either left out or may fail or lose data when run.
+type Warning struct {
+	Change  Change
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Change.Path(), w.Message)
+}
+
+// The phases of a migration. Foreign keys and constraints are dropped
+// before the columns and tables they use, and added after them. Types and
+// sequences are created before the tables that use them, and types dropped
+// after. Sequences are owned by their columns, and indexes created, once
+// the columns exist, and before the foreign keys that may need a unique
+// index.
+const (
+	dropForeignKeys = iota
+	dropConstraints
+	createTypes
+	createSequences
+	createTables
+	addColumns
+	alterColumns
+	dropColumns
+	dropTables
+	dropTypes
+	ownSequences
+	addConstraints
+	createIndexes
+	addForeignKeys
+	phases
+)
+
+// Migration returns the statements that turn d.Old into d.New, in an order
+// that runs against the old schema, along with the changes it could not
+// express safely. Sequences only d.New has are created along with the
+// tables, and new tables get their indexes and the column defaults and
+// constraints pg_dump adds with ALTER TABLE.
+func (d *Diff) Migration() ([]string, []Warning) {
+	m := &migration{diff: d}
+	for _, seq := range d.New.Sequences {
+		if d.Old.Sequence(seq.QualifiedName()) != nil {
+			continue
+		}
+		m.add(createSequences, "%s", statement(seq.SQL))
+		if seq.Ownership.SQL != "" {
+			m.add(ownSequences, "%s", statement(seq.Ownership.SQL))
+		}
+	}
+	for _, c := range d.Changes {
+		m.change(c)
+	}
+
+	var statements []string
+	for _, phase := range m.phases {
+		statements = append(statements, phase...)
+	}
+	return statements, m.warnings
+}
+
+// WriteMigration writes the statements of Migration, separated by blank
+// lines, after a comment listing the warnings.
+func (d *Diff) WriteMigration(w io.Writer) ([]Warning, error) {
+	statements, warnings := d.Migration()
+	for _, warning := range warnings {
+		if _, err := fmt.Fprintf(w, "-- WARNING: %s\n", warning); err != nil {
+			return warnings, err
+		}
+	}
+	for i, stmt := range statements {
+		if i > 0 || len(warnings) > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return warnings, err
+			}
+		}
+		if _, err := fmt.Fprintln(w, stmt); err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+type migration struct {
+	diff     *Diff
+	phases   [phases][]string
+	warnings []Warning
+}
+
+func (m *migration) add(phase int, format string, args ...interface{}) {
+	m.phases[phase] = append(m.phases[phase], fmt.Sprintf(format, args...))
+}
+
+func (m *migration) warn(c Change, format string, args ...interface{}) {
+	m.warnings = append(m.warnings, Warning{c, fmt.Sprintf(format, args...)})
+}
+
+func (m *migration) change(c Change) {
+	switch c.Object {
+	case Table:
+		m.table(c)
+	case Column:
+		m.column(c)
+	case Constraint:
+		m.constraint(c)
+	case ForeignKey:
+		m.foreignKey(c)
+	case Enum:
+		m.enum(c)
+	case EnumValue:
+		m.enumValue(c)
+	}
+}
+
+func (m *migration) table(c Change) {
+	switch c.Kind {
+	case Added:
+		table := m.diff.New.Table(c.Name)
+		m.add(createTables, "%s", statement(table.SQL))
+		for _, alteration := range table.ColumnAlterations {
+			m.add(createTables, "%s", statement(alteration.SQL))
+		}
+		for _, constraint := range table.AddedConstraints {
+			m.add(addConstraints, "%s", statement(constraint.SQL))
+		}
+		for _, index := range m.diff.New.Indexes {
+			if index.TableName() == c.Name {
+				m.add(createIndexes, "%s", statement(index.SQL))
+			}
+		}
+	case Removed:
+		table := m.diff.Old.Table(c.Name)
+		m.add(dropTables, "DROP TABLE %s;", schema.QuotedName(table.Schema, table.Name))
+	case Changed:
+		if c.Field != "primary key" {
+			return
+		}
+		oldTable, newTable := m.diff.Old.Table(c.Name), m.diff.New.Table(c.Name)
+		name := schema.QuotedName(newTable.Schema, newTable.Name)
+		if c.Old != "" {
+			constraint := primaryKeyName(*oldTable)
+			if constraint == "" {
+				constraint = oldTable.Name + "_pkey"
+				m.warn(c, "assuming the old primary key is named %s", constraint)
+			}
+			m.add(dropConstraints, "ALTER TABLE %s DROP CONSTRAINT %s;", name, schema.QuoteIdent(constraint))
+		}
+		if c.New != "" {
+			m.add(addConstraints, "ALTER TABLE %s ADD PRIMARY KEY (%s);", name, quoteList(newTable.PrimaryKey))
+		}
+	}
+}
+
+func (m *migration) column(c Change) {
+	switch c.Kind {
+	case Added:
+		table := m.diff.New.Table(c.Parent)
+		col := table.Column(c.Name)
+		def := fmt.Sprintf("%s %s", schema.QuoteIdent(col.Name), col.Type)
+		if col.Default != "" {
+			def += " DEFAULT " + col.Default
+		}
+		if col.IsNotNull {
+			def += " NOT NULL"
+			if col.Default == "" {
+				m.warn(c, "adding a NOT NULL column without a default fails if the table has rows")
+			}
+		}
+		m.add(addColumns, "ALTER TABLE %s ADD COLUMN %s;", schema.QuotedName(table.Schema, table.Name), def)
+	case Removed:
+		table := m.diff.Old.Table(c.Parent)
+		m.add(dropColumns, "ALTER TABLE %s DROP COLUMN %s;", schema.QuotedName(table.Schema, table.Name), schema.QuoteIdent(c.Name))
+	case Changed:
+		table := m.diff.New.Table(c.Parent)
+		alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", schema.QuotedName(table.Schema, table.Name), schema.QuoteIdent(c.Name))
+		switch c.Field {
+		case "type":
+			m.add(alterColumns, "%s TYPE %s;", alter, c.New)
+			// Widening a type, such as varchar(100) to text, is safe
+			if narrowed, ok := narrows(c.Old, c.New); !ok {
+				m.warn(c, "changing the type from %s to %s may fail or rewrite the table", c.Old, c.New)
+			} else if narrowed {
+				m.warn(c, "narrowing the type from %s to %s fails if a value does not fit", c.Old, c.New)
+			}
+		case "default":
+			if c.New == "" {
+				m.add(alterColumns, "%s DROP DEFAULT;", alter)
+			} else {
+				m.add(alterColumns, "%s SET DEFAULT %s;", alter, c.New)
+			}
+		case "nullability":
+			if c.New == "NOT NULL" {
+				m.add(alterColumns, "%s SET NOT NULL;", alter)
+				m.warn(c, "setting NOT NULL fails if the column holds NULLs")
+			} else {
+				m.add(alterColumns, "%s DROP NOT NULL;", alter)
+			}
+		}
+	}
+}
+
+func (m *migration) constraint(c Change) {
+	name, body := splitConstraint(c.Name)
+	if strings.HasPrefix(body, "PRIMARY KEY") {
+		// Primary keys are handled by the table's "primary key" change
+		return
+	}
+	switch c.Kind {
+	case Added:
+		table := m.diff.New.Table(c.Parent)
+		m.add(addConstraints, "ALTER TABLE %s ADD %s;", schema.QuotedName(table.Schema, table.Name), c.Name)
+	case Removed:
+		if name == "" {
+			m.warn(c, "cannot drop a constraint without a name")
+			return
+		}
+		table := m.diff.Old.Table(c.Parent)
+		m.add(dropConstraints, "ALTER TABLE %s DROP CONSTRAINT %s;", schema.QuotedName(table.Schema, table.Name), schema.QuoteIdent(name))
+	}
+}
+
+func (m *migration) foreignKey(c Change) {
+	if c.Kind != Added {
+		fk := findForeignKey(m.diff.Old, c.Parent, c.Name)
+		m.add(dropForeignKeys, "ALTER TABLE %s DROP CONSTRAINT %s;", schema.QuotedName(fk.Schema, fk.Table), schema.QuoteIdent(fk.Name))
+	}
+	if c.Kind != Removed {
+		fk := findForeignKey(m.diff.New, c.Parent, c.Name)
+		m.add(addForeignKeys, "%s", statement(fk.SQL))
+	}
+}
+
+func (m *migration) enum(c Change) {
+	switch c.Kind {
+	case Added:
+		m.add(createTypes, "%s", statement(m.diff.New.Enum(c.Name).SQL))
+	case Removed:
+		enum := m.diff.Old.Enum(c.Name)
+		m.add(dropTypes, "DROP TYPE %s;", schema.QuotedName(enum.Schema, enum.Name))
+	}
+}
+
+func (m *migration) enumValue(c Change) {
+	if c.Kind == Removed {
+		m.warn(c, "PostgreSQL cannot remove a value from an enum type; the type has to be recreated")
+		return
+	}
+
+	enum := m.diff.New.Enum(c.Parent)
+	stmt := fmt.Sprintf("ALTER TYPE %s ADD VALUE %s", schema.QuotedName(enum.Schema, enum.Name), quoteLiteral(c.Name))
+	for i, value := range enum.Values {
+		if value != c.Name {
+			continue
+		}
+		// Values are added in order, so the one before is always there
+		if i > 0 {
+			stmt += " AFTER " + quoteLiteral(enum.Values[i-1])
+		} else if len(enum.Values) > 1 {
+			stmt += " BEFORE " + quoteLiteral(enum.Values[1])
+		}
+	}
+	m.add(createTypes, "%s;", stmt)
+}
+
+var constraintName = regexp.MustCompile(`(?is)^CONSTRAINT\s+("(?:[^"]|"")+"|\S+)\s+(.*)$`)
+
+// splitConstraint splits a constraint definition such as
+// "CONSTRAINT users_email_key UNIQUE (email)" into its name, which is empty
+// for unnamed constraints, and the rest of the definition.
+func splitConstraint(def string) (name, body string) {
+	match := constraintName.FindStringSubmatch(def)
+	if match == nil {
+		return "", def
+	}
+	name = match[1]
+	if strings.HasPrefix(name, `"`) {
+		name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	}
+	return name, match[2]
+}
+
+// primaryKeyName returns the name of the table's primary key constraint, or
+// "" if it is not known.
+func primaryKeyName(table schema.TableDef) string {
+	for _, def := range table.Constraints {
+		if name, body := splitConstraint(def); strings.HasPrefix(body, "PRIMARY KEY") {
+			return name
+		}
+	}
+	return ""
+}
+
+// statement right-trims sql and makes sure it ends with a semicolon.
+func statement(sql string) string {
+	sql = strings.TrimRight(sql, " \t\r\n")
+	if !strings.HasSuffix(sql, ";") {
+		sql += ";"
+	}
+	return sql
+}
+
+func quoteList(names []string) string {
+	var quoted []string
+	for _, name := range names {
+		quoted = append(quoted, schema.QuoteIdent(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,190 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigration(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+		warnings int
+	}{
+		{
+			name: "foreign keys around the tables",
+			old: `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+CREATE TABLE public.posts (
+    id bigint NOT NULL,
+    user_id bigint
+);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.posts
+    ADD CONSTRAINT fk_posts_user FOREIGN KEY (user_id) REFERENCES public.users(id);
+`,
+			new: `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+CREATE TABLE public.comments (
+    id bigint NOT NULL,
+    user_id bigint
+);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.comments
+    ADD CONSTRAINT comments_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.comments
+    ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES public.users(id);
+`,
+			want: []string{
+				"ALTER TABLE public.posts DROP CONSTRAINT fk_posts_user;",
+				"CREATE TABLE public.comments (\n    id bigint NOT NULL,\n    user_id bigint\n);",
+				"DROP TABLE public.posts;",
+				"ALTER TABLE ONLY public.comments\n    ADD CONSTRAINT comments_pkey PRIMARY KEY (id);",
+				"ALTER TABLE ONLY public.comments\n    ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES public.users(id);",
+			},
+		},
+		{
+			name: "types before the columns using them",
+			old: `CREATE TABLE public.users (
+    id bigint NOT NULL,
+    name text
+);
+`,
+			new: `CREATE TYPE public.role AS ENUM (
+    'admin'
+);
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    role public.role
+);
+`,
+			want: []string{
+				"CREATE TYPE public.role AS ENUM (\n    'admin'\n);",
+				"ALTER TABLE public.users ADD COLUMN role public.role;",
+				"ALTER TABLE public.users DROP COLUMN name;",
+			},
+		},
+		{
+			name: "types dropped after the columns using them",
+			old: `CREATE TYPE public.role AS ENUM (
+    'admin'
+);
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    role public.role
+);
+`,
+			new: `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+`,
+			want: []string{
+				"ALTER TABLE public.users DROP COLUMN role;",
+				"DROP TYPE public.role;",
+			},
+		},
+		{
+			name: "constraints dropped first and added last",
+			old: `CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email text
+);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+`,
+			new: `CREATE TABLE public.users (
+    id bigint NOT NULL,
+    login text
+);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_login_key UNIQUE (login);
+`,
+			want: []string{
+				"ALTER TABLE public.users DROP CONSTRAINT users_email_key;",
+				"ALTER TABLE public.users ADD COLUMN login text;",
+				"ALTER TABLE public.users DROP COLUMN email;",
+				"ALTER TABLE public.users ADD CONSTRAINT users_login_key UNIQUE (login);",
+			},
+		},
+		{
+			name: "new sequence before its table and owned after it",
+			old:  "",
+			new: `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+CREATE SEQUENCE public.users_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+CREATE INDEX index_users_on_id ON public.users USING btree (id);
+`,
+			want: []string{
+				"CREATE SEQUENCE public.users_id_seq\n    START WITH 1\n    INCREMENT BY 1\n    NO MINVALUE\n    NO MAXVALUE\n    CACHE 1;",
+				"CREATE TABLE public.users (\n    id bigint NOT NULL\n);",
+				"ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);",
+				"ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;",
+				"CREATE INDEX index_users_on_id ON public.users USING btree (id);",
+			},
+		},
+		{
+			name: "narrowed type warns",
+			old: `CREATE TABLE public.users (
+    email character varying(255)
+);
+`,
+			new: `CREATE TABLE public.users (
+    email character varying(100)
+);
+`,
+			want:     []string{"ALTER TABLE public.users ALTER COLUMN email TYPE character varying(100);"},
+			warnings: 1,
+		},
+		{
+			name: "widened type does not warn",
+			old: `CREATE TABLE public.users (
+    age integer
+);
+`,
+			new: `CREATE TABLE public.users (
+    age bigint
+);
+`,
+			want: []string{"ALTER TABLE public.users ALTER COLUMN age TYPE bigint;"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings := Compare(parseDump(t, tt.old), parseDump(t, tt.new)).Migration()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Migration() statements =\n%q\nwant\n%q", got, tt.want)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("Migration() warnings = %v, want %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
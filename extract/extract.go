@@ -0,0 +1,203 @@
+// Package extract cuts the tables of one domain, together with the types
+// and constraints they need, out of a parsed schema so they can be loaded
+// into an empty database.
+package extract
+
+import "github.com/sent-hil/pg_struct_parser/schema"
+
+// StubLevel selects how much of a related table a stub keeps.
+type StubLevel int
+
+const (
+	// StubReferenced keeps the primary key and the columns used by the
+	// extracted foreign keys that start or end at the table.
+	StubReferenced StubLevel = iota
+	// StubPrimaryKey keeps only the primary key columns. Foreign keys that
+	// need other columns of the stub are left out.
+	StubPrimaryKey
+	// StubFull keeps every column, but still drops defaults and
+	// constraints other than the primary key.
+	StubFull
+)
+
+// Options controls which objects Extract selects.
+type Options struct {
+	// Prefix selects the public tables named <Prefix>_*.
+	Prefix string
+	// Include holds patterns, as understood by schema.ParsePattern, that
+	// select tables in addition to Prefix.
+	Include []string
+	// Exclude holds patterns for tables that are never extracted, however
+	// they would otherwise be selected. Foreign keys to them are left out.
+	Exclude []string
+	// Whitelist holds patterns for tables that are selected and always
+	// included in full, even when they are only related to the selection.
+	Whitelist []string
+	// Depth is the number of foreign key hops to follow from the selected
+	// tables to the tables they reference, which are then included in
+	// full. Zero disables this, and a negative depth follows foreign keys
+	// until no new tables are found.
+	Depth int
+	// Inward makes Depth also follow foreign keys that point into the
+	// selection, adding the tables that reference the selected tables.
+	Inward bool
+	// Related adds the tables that reference, or are referenced by, the
+	// selected tables. Whitelisted related tables are written in full, the
+	// rest as stubs cut down according to Stubs.
+	Related bool
+	// Stubs is the amount of each related table a stub keeps.
+	Stubs StubLevel
+	// GuessRelationships also treats <name>_id columns without a foreign
+	// key as references to the table <name> or <name>s.
+	GuessRelationships bool
+}
+
+// Extract returns the subset of s selected by opts, along with every
+// extension s installs, the views that only read from extracted tables and
+// columns, the triggers of the tables included in full, the functions the
+// extracted defaults, check constraints, views and triggers call, and the
+// user-defined types and sequences the extracted columns and functions
+// use. Stub tables are returned with their columns, SQL and Stmt cut down
+// to the stub. Only foreign keys between two extracted tables, over
+// columns those tables kept, are included, so the result never references
+// a table or column it does not create. Tables included in full and
+// materialized views keep all their indexes, and stubs keep the plain
+// unique indexes over columns they kept.
+func Extract(s *schema.Schema, opts Options) (*schema.Schema, error) {
+	include := append([]string(nil), opts.Include...)
+	if opts.Prefix != "" {
+		include = append(include, schema.PrefixPattern(opts.Prefix))
+	}
+	include = append(include, opts.Whitelist...)
+	sel, err := schema.NewSelector(include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	whitelist, err := schema.NewSelector(opts.Whitelist, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, table := range s.Filter(sel).Tables {
+		selected = append(selected, table.QualifiedName())
+	}
+
+	graph := schema.NewGraph(s, opts.GuessRelationships)
+	if opts.Depth != 0 {
+		for _, name := range graph.Closure(selected, opts.Depth, opts.Inward) {
+			if !sel.Excludes(name) {
+				selected = append(selected, name)
+			}
+		}
+	}
+
+	extracted := &schema.Schema{Extensions: s.Extensions}
+	for _, name := range selected {
+		if table := s.Table(name); table != nil {
+			extracted.Tables = append(extracted.Tables, *table)
+		}
+	}
+
+	if opts.Related {
+		var stubs []string
+		for _, name := range graph.Neighbours(selected) {
+			if table := s.Table(name); table != nil && !whitelist.Includes(name) && !sel.Excludes(name) {
+				stubs = append(stubs, name)
+			}
+		}
+
+		included := make(map[string]bool)
+		for _, name := range append(selected, stubs...) {
+			included[name] = true
+		}
+		for _, name := range stubs {
+			table := s.Table(name)
+			stub, err := schema.Stub(*table, stubColumns(s, *table, included, opts.Stubs))
+			if err != nil {
+				return nil, err
+			}
+			extracted.Tables = append(extracted.Tables, stub)
+		}
+	}
+
+	extracted.Views = s.ViewsOver(extracted.Tables)
+
+	// Stubs have no triggers, but keep the unique indexes over their
+	// columns, since foreign keys may reference the columns through them
+	full := make(map[string]bool)
+	var fullTables []schema.TableDef
+	for _, name := range selected {
+		full[name] = true
+		if table := s.Table(name); table != nil {
+			fullTables = append(fullTables, *table)
+		}
+	}
+	extracted.Triggers = s.TriggersOn(fullTables)
+	extracted.Functions = s.UsedFunctions(extracted.Tables, extracted.Views, extracted.Triggers)
+	extracted.Enums = s.UsedEnums(extracted.Tables, extracted.Functions)
+	extracted.Domains = s.UsedDomains(extracted.Tables, extracted.Functions)
+	extracted.CompositeTypes = s.UsedCompositeTypes(extracted.Tables, extracted.Functions)
+	extracted.RangeTypes = s.UsedRangeTypes(extracted.Tables, extracted.Functions)
+	extracted.Sequences = s.UsedSequences(extracted.Tables, extracted.Functions)
+
+	for _, view := range extracted.Views {
+		full[view.QualifiedName()] = true
+	}
+	for _, index := range s.Indexes {
+		if full[index.TableName()] {
+			extracted.Indexes = append(extracted.Indexes, index)
+			continue
+		}
+		columns, plain := index.ColumnNames()
+		if index.Unique && plain && index.Where == "" && hasColumns(extracted.Table(index.TableName()), columns) {
+			extracted.Indexes = append(extracted.Indexes, index)
+		}
+	}
+
+	for _, fk := range s.ForeignKeys {
+		if hasColumns(extracted.Table(fk.FromTable()), fk.Columns) && hasColumns(extracted.Table(fk.ToTable()), fk.RefColumns) {
+			extracted.ForeignKeys = append(extracted.ForeignKeys, fk)
+		}
+	}
+	return extracted, nil
+}
+
+// stubColumns returns the columns of table, beyond its primary key, that a
+// stub at level keeps.
+func stubColumns(s *schema.Schema, table schema.TableDef, included map[string]bool, level StubLevel) []string {
+	var columns []string
+	switch level {
+	case StubFull:
+		for _, col := range table.Columns {
+			columns = append(columns, col.Name)
+		}
+	case StubReferenced:
+		name := table.QualifiedName()
+		for _, fk := range s.ForeignKeys {
+			if !included[fk.FromTable()] || !included[fk.ToTable()] {
+				continue
+			}
+			if fk.FromTable() == name {
+				columns = append(columns, fk.Columns...)
+			}
+			if fk.ToTable() == name {
+				columns = append(columns, fk.RefColumns...)
+			}
+		}
+	}
+	return columns
+}
+
+// hasColumns reports whether table exists and has every one of columns.
+func hasColumns(table *schema.TableDef, columns []string) bool {
+	if table == nil {
+		return false
+	}
+	for _, column := range columns {
+		if table.Column(column) == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -3,6 +3,9 @@ import { Node, Edge, MarkerType } from 'reactflow'
 interface Column {
   name: string
   type: string
+  baseType?: string
+  arrayDims?: number
+  userType?: string
   isNotNull: boolean
   default?: string
   isPrimaryKey: boolean
@@ -14,6 +17,22 @@ interface Column {
   enumValues?: string[]
 }
 
+interface UserType {
+  name: string
+  schema: string
+  kind: 'enum' | 'domain' | 'composite' | 'range'
+  values?: string[]
+  baseType?: string
+  isNotNull?: boolean
+  default?: string
+  checks?: string[]
+  attributes?: Array<{
+    name: string
+    type: string
+  }>
+  subtype?: string
+}
+
 interface Table {
   name: string
   schema: string
@@ -27,7 +46,15 @@ export interface SchemaData {
     fromColumn: string
     toTable: string
     toColumn: string
+    name?: string
+    onDelete?: string
+    onUpdate?: string
+    match?: string
+    deferrable?: boolean
+    initiallyDeferred?: boolean
+    notValid?: boolean
   }>
+  types?: UserType[]
 }
 
 const parseCreateTable = (sql: string): Table => {
@@ -0,0 +1,231 @@
+package schema
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// replicaIdentities maps ReplicaIdentityStmt.IdentityType to the SQL
+// keywords of REPLICA IDENTITY.
+var replicaIdentities = map[string]string{
+	"d": "DEFAULT",
+	"f": "FULL",
+	"n": "NOTHING",
+	"i": "USING INDEX",
+}
+
+// ApplyAlterTable applies every subcommand of an ALTER TABLE statement that
+// changes a table's columns, owner or replica identity to the table in s.
+// Columns added or dropped, and NOT NULL set or dropped on the columns the
+// table declares, are also applied to its Stmt, and its SQL is deparsed
+// again so that it creates the altered table. Subcommands that set a
+// column default or change an identity, and those on columns the table
+// inherits, are kept in the table's ColumnAlterations instead, with their
+// SQL deparsed. Constraints, including foreign keys declared on an added
+// column, are left to ParseForeignKeys and ParseConstraints, and other
+// subcommands are ignored.
+//
+// pg_dump also uses ALTER TABLE for sequences and views, so statements on
+// relations s has no table for are skipped. pg_dump leaves the columns a
+// table inherits out of its CREATE TABLE, so ALTER COLUMN subcommands on
+// columns the table does not declare only keep their statement. An error
+// is returned for malformed subcommands.
+func (s *Schema) ApplyAlterTable(stmt *pg_query.AlterTableStmt) error {
+	if stmt == nil || stmt.Relation == nil {
+		return fmt.Errorf("ALTER TABLE without a table")
+	}
+	table := s.Table(getTableName(stmt.Relation))
+	if table == nil {
+		return nil
+	}
+
+	rewritten := false
+	for _, cmd := range stmt.Cmds {
+		alterCmd := cmd.GetAlterTableCmd()
+		if alterCmd == nil {
+			return fmt.Errorf("unexpected command in ALTER TABLE %s", table.QualifiedName())
+		}
+		declared := table.Column(alterCmd.GetName()) != nil
+		if err := table.applyAlterTableCmd(alterCmd); err != nil {
+			return fmt.Errorf("error altering table %s: %v", table.QualifiedName(), err)
+		}
+
+		switch alterCmd.GetSubtype() {
+		case pg_query.AlterTableType_AT_AddColumn, pg_query.AlterTableType_AT_DropColumn:
+			rewritten = table.rewriteStmt(alterCmd) || rewritten
+			continue
+		case pg_query.AlterTableType_AT_SetNotNull, pg_query.AlterTableType_AT_DropNotNull:
+			if declared {
+				rewritten = table.rewriteStmt(alterCmd) || rewritten
+				continue
+			}
+		case pg_query.AlterTableType_AT_ColumnDefault, pg_query.AlterTableType_AT_AddIdentity, pg_query.AlterTableType_AT_DropIdentity:
+		default:
+			continue
+		}
+
+		// Defaults may call sequences created after the table, so they
+		// are kept as statements of their own
+		alteration := Statement{Node: singleCommand(stmt, cmd)}
+		sql, err := Deparse(alteration.Node)
+		if err != nil {
+			return fmt.Errorf("error deparsing ALTER COLUMN %s of %s: %v", alterCmd.GetName(), table.QualifiedName(), err)
+		}
+		alteration.SQL = sql
+		table.ColumnAlterations = append(table.ColumnAlterations, alteration)
+	}
+
+	if rewritten {
+		sql, err := Deparse(table.Stmt)
+		if err != nil {
+			return fmt.Errorf("error deparsing altered table %s: %v", table.QualifiedName(), err)
+		}
+		table.SQL = sql
+	}
+	return nil
+}
+
+func (t *TableDef) applyAlterTableCmd(cmd *pg_query.AlterTableCmd) error {
+	switch cmd.GetSubtype() {
+	case pg_query.AlterTableType_AT_AddColumn:
+		def := cmd.GetDef().GetColumnDef()
+		if def == nil {
+			return fmt.Errorf("ADD COLUMN without a column definition")
+		}
+		if t.Column(def.Colname) != nil {
+			if cmd.GetMissingOk() {
+				return nil
+			}
+			return fmt.Errorf("column %s already exists", def.Colname)
+		}
+		t.addColumn(processColumnDef(def))
+	case pg_query.AlterTableType_AT_DropColumn:
+		for i, col := range t.Columns {
+			if col.Name == cmd.GetName() {
+				t.Columns = append(t.Columns[:i], t.Columns[i+1:]...)
+				return nil
+			}
+		}
+		if !cmd.GetMissingOk() {
+			return fmt.Errorf("column %s does not exist", cmd.GetName())
+		}
+	case pg_query.AlterTableType_AT_ColumnDefault:
+		// A nil Def is DROP DEFAULT
+		if col := t.Column(cmd.GetName()); col != nil {
+			col.setDefault(cmd.GetDef())
+		}
+	case pg_query.AlterTableType_AT_AddIdentity:
+		constraint := cmd.GetDef().GetConstraint()
+		if constraint.GetContype() != pg_query.ConstrType_CONSTR_IDENTITY {
+			return fmt.Errorf("ADD GENERATED without an identity")
+		}
+		if col := t.Column(cmd.GetName()); col != nil {
+			col.Identity = identityKinds[constraint.GetGeneratedWhen()]
+			col.Sequence = identitySequence(constraint)
+			if col.Sequence == "" {
+				col.Sequence = t.implicitSequence(col.Name)
+			}
+		}
+	case pg_query.AlterTableType_AT_DropIdentity:
+		if col := t.Column(cmd.GetName()); col != nil {
+			col.Identity = ""
+			col.Sequence = ""
+		}
+	case pg_query.AlterTableType_AT_SetNotNull, pg_query.AlterTableType_AT_DropNotNull:
+		if col := t.Column(cmd.GetName()); col != nil {
+			col.IsNotNull = cmd.GetSubtype() == pg_query.AlterTableType_AT_SetNotNull
+		}
+	case pg_query.AlterTableType_AT_ChangeOwner:
+		if cmd.GetNewowner() == nil {
+			return fmt.Errorf("OWNER TO without an owner")
+		}
+		t.Owner = cmd.GetNewowner().GetRolename()
+	case pg_query.AlterTableType_AT_ReplicaIdentity:
+		identity := cmd.GetDef().GetReplicaIdentityStmt()
+		if identity == nil {
+			return fmt.Errorf("REPLICA IDENTITY without an identity")
+		}
+		keyword, ok := replicaIdentities[identity.GetIdentityType()]
+		if !ok {
+			return fmt.Errorf("unknown replica identity %q", identity.GetIdentityType())
+		}
+		if identity.GetIdentityType() == "i" {
+			keyword += " " + QuoteIdent(identity.GetName())
+		}
+		t.ReplicaIdentity = keyword
+	}
+	return nil
+}
+
+// rewriteStmt applies an ADD COLUMN, DROP COLUMN, SET NOT NULL or DROP NOT
+// NULL subcommand to a copy of the table's CREATE TABLE statement, and
+// reports whether the statement changed. The parse tree of the dump is
+// left as it is.
+func (t *TableDef) rewriteStmt(cmd *pg_query.AlterTableCmd) bool {
+	create := t.Stmt.GetCreateStmt()
+	if create == nil {
+		return false
+	}
+
+	var elements []*pg_query.Node
+	changed := false
+	switch cmd.GetSubtype() {
+	case pg_query.AlterTableType_AT_AddColumn:
+		def := cmd.GetDef().GetColumnDef()
+		for _, element := range create.TableElts {
+			if element.GetColumnDef().GetColname() == def.GetColname() {
+				// ADD COLUMN IF NOT EXISTS of a column the table has
+				return false
+			}
+		}
+		// Foreign keys are written separately, as for CREATE TABLE
+		added := withoutForeignKeys(&pg_query.CreateStmt{TableElts: []*pg_query.Node{cmd.GetDef()}})
+		elements = append(append(elements, create.TableElts...), added.TableElts...)
+		changed = true
+	case pg_query.AlterTableType_AT_DropColumn:
+		for _, element := range create.TableElts {
+			if element.GetColumnDef() != nil && element.GetColumnDef().GetColname() == cmd.GetName() {
+				changed = true
+				continue
+			}
+			elements = append(elements, element)
+		}
+	case pg_query.AlterTableType_AT_SetNotNull, pg_query.AlterTableType_AT_DropNotNull:
+		for _, element := range create.TableElts {
+			def := element.GetColumnDef()
+			if def == nil || def.GetColname() != cmd.GetName() {
+				elements = append(elements, element)
+				continue
+			}
+			changed = true
+			elements = append(elements, withNotNull(def, cmd.GetSubtype() == pg_query.AlterTableType_AT_SetNotNull))
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	t.Stmt = &pg_query.Node{Node: &pg_query.Node_CreateStmt{CreateStmt: withElements(create, elements)}}
+	return true
+}
+
+// withNotNull returns a copy of the column definition node def with a NOT
+// NULL constraint if notNull is set, and without one otherwise.
+func withNotNull(def *pg_query.ColumnDef, notNull bool) *pg_query.Node {
+	var constraints []*pg_query.Node
+	for _, constraint := range def.Constraints {
+		switch constraint.GetConstraint().GetContype() {
+		case pg_query.ConstrType_CONSTR_NOTNULL, pg_query.ConstrType_CONSTR_NULL:
+		default:
+			constraints = append(constraints, constraint)
+		}
+	}
+	if notNull {
+		constraints = append(constraints, &pg_query.Node{Node: &pg_query.Node_Constraint{Constraint: &pg_query.Constraint{
+			Contype: pg_query.ConstrType_CONSTR_NOTNULL,
+		}}})
+	}
+
+	return withConstraints(def, constraints)
+}
@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyAlterTable(t *testing.T) {
+	const tables = `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+CREATE TABLE public.posts (
+    id bigint NOT NULL,
+    title text
+);
+
+CREATE TABLE public.drafts (
+    body text
+)
+INHERITS (public.posts);
+`
+	tests := []struct {
+		name  string
+		sql   string
+		table string
+		// columns lists the table's columns, with " NOT NULL" appended to
+		// those that are
+		columns     []string
+		alterations int
+		foreignKeys []string
+	}{
+		{
+			name:    "add column",
+			sql:     "ALTER TABLE public.posts ADD COLUMN body text NOT NULL;",
+			table:   "public.posts",
+			columns: []string{"id NOT NULL", "title", "body NOT NULL"},
+		},
+		{
+			name:    "drop column",
+			sql:     "ALTER TABLE public.posts DROP COLUMN title;",
+			table:   "public.posts",
+			columns: []string{"id NOT NULL"},
+		},
+		{
+			name:    "set and drop not null",
+			sql:     "ALTER TABLE public.posts ALTER COLUMN title SET NOT NULL, ALTER COLUMN id DROP NOT NULL;",
+			table:   "public.posts",
+			columns: []string{"id", "title NOT NULL"},
+		},
+		{
+			name:        "default",
+			sql:         "ALTER TABLE ONLY public.posts ALTER COLUMN title SET DEFAULT 'untitled'::text;",
+			table:       "public.posts",
+			columns:     []string{"id NOT NULL", "title"},
+			alterations: 1,
+		},
+		{
+			name:        "inherited column",
+			sql:         "ALTER TABLE ONLY public.drafts ALTER COLUMN title SET NOT NULL;",
+			table:       "public.drafts",
+			columns:     []string{"body"},
+			alterations: 1,
+		},
+		{
+			name:        "added column with a foreign key",
+			sql:         "ALTER TABLE public.posts ADD COLUMN user_id bigint REFERENCES public.users(id) ON DELETE CASCADE;",
+			table:       "public.posts",
+			columns:     []string{"id NOT NULL", "title", "user_id"},
+			foreignKeys: []string{"posts_user_id_fkey (user_id) REFERENCES public.users(id) ON DELETE CASCADE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(strings.NewReader(tables + "\n" + tt.sql + "\n"))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			table := s.Table(tt.table)
+			if table == nil {
+				t.Fatalf("table %s not found", tt.table)
+			}
+
+			var columns []string
+			for _, col := range table.Columns {
+				column := col.Name
+				if col.IsNotNull {
+					column += " NOT NULL"
+				}
+				columns = append(columns, column)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", columns, tt.columns)
+			}
+			if len(table.ColumnAlterations) != tt.alterations {
+				t.Errorf("column alterations = %d, want %d", len(table.ColumnAlterations), tt.alterations)
+			}
+			if strings.Contains(table.SQL, "REFERENCES") {
+				t.Errorf("table SQL keeps a foreign key: %s", table.SQL)
+			}
+
+			var foreignKeys []string
+			for _, fk := range s.ForeignKeys {
+				foreignKeys = append(foreignKeys, fk.Name+" ("+strings.Join(fk.Columns, ", ")+") REFERENCES "+
+					fk.ToTable()+"("+strings.Join(fk.RefColumns, ", ")+") "+fk.Options())
+				if !strings.Contains(fk.SQL, "FOREIGN KEY") {
+					t.Errorf("foreign key SQL = %q, want an ADD CONSTRAINT ... FOREIGN KEY", fk.SQL)
+				}
+			}
+			if !reflect.DeepEqual(foreignKeys, tt.foreignKeys) {
+				t.Errorf("foreign keys = %q, want %q", foreignKeys, tt.foreignKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// Deparse turns a statement node back into SQL, terminated by a semicolon.
+func Deparse(stmt *pg_query.Node) (string, error) {
+	sql, err := pg_query.Deparse(&pg_query.ParseResult{
+		Stmts: []*pg_query.RawStmt{{Stmt: stmt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return sql + ";", nil
+}
+
+// DeparseExpr turns an expression node, such as a default or an index
+// predicate, back into SQL.
+func DeparseExpr(expr *pg_query.Node) (string, error) {
+	// Deparse only handles statements, so wrap expr in a SELECT
+	sql, err := pg_query.Deparse(&pg_query.ParseResult{
+		Stmts: []*pg_query.RawStmt{{Stmt: &pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: &pg_query.SelectStmt{
+			TargetList: []*pg_query.Node{{Node: &pg_query.Node_ResTarget{ResTarget: &pg_query.ResTarget{Val: expr}}}},
+		}}}}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(sql, "SELECT "), nil
+}
+
+// Deparsed returns a copy of s whose SQL fields are regenerated from each
+// object's Stmt instead of copied from the dump, so changes made to the
+// parsed statements show up in the output. Objects without a Stmt keep
+// their SQL.
+func (s *Schema) Deparsed() (*Schema, error) {
+	deparsed := *s
+	deparsed.Extensions = append([]ExtensionDef(nil), s.Extensions...)
+	deparsed.Tables = append([]TableDef(nil), s.Tables...)
+	deparsed.Enums = append([]EnumDef(nil), s.Enums...)
+	deparsed.ForeignKeys = append([]ForeignKeyDef(nil), s.ForeignKeys...)
+	deparsed.Indexes = append([]IndexDef(nil), s.Indexes...)
+	deparsed.Sequences = append([]SequenceDef(nil), s.Sequences...)
+	deparsed.Domains = append([]DomainDef(nil), s.Domains...)
+	deparsed.CompositeTypes = append([]CompositeTypeDef(nil), s.CompositeTypes...)
+	deparsed.RangeTypes = append([]RangeTypeDef(nil), s.RangeTypes...)
+	deparsed.Views = append([]ViewDef(nil), s.Views...)
+	deparsed.Functions = append([]FunctionDef(nil), s.Functions...)
+	deparsed.Triggers = append([]TriggerDef(nil), s.Triggers...)
+
+	for i := range deparsed.Extensions {
+		ext := &deparsed.Extensions[i]
+		if err := deparseInto(&ext.SQL, ext.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing extension %s: %v", ext.Name, err)
+		}
+	}
+	for i := range deparsed.Enums {
+		enum := &deparsed.Enums[i]
+		if err := deparseInto(&enum.SQL, enum.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing type %s: %v", enum.QualifiedName(), err)
+		}
+	}
+	for i := range deparsed.Domains {
+		domain := &deparsed.Domains[i]
+		if err := deparseInto(&domain.SQL, domain.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing domain %s: %v", domain.QualifiedName(), err)
+		}
+	}
+	for i := range deparsed.CompositeTypes {
+		typ := &deparsed.CompositeTypes[i]
+		if err := deparseInto(&typ.SQL, typ.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing type %s: %v", typ.QualifiedName(), err)
+		}
+	}
+	for i := range deparsed.RangeTypes {
+		rng := &deparsed.RangeTypes[i]
+		if err := deparseInto(&rng.SQL, rng.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing type %s: %v", rng.QualifiedName(), err)
+		}
+	}
+	for i := range deparsed.Sequences {
+		seq := &deparsed.Sequences[i]
+		if err := deparseInto(&seq.SQL, seq.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing sequence %s: %v", seq.QualifiedName(), err)
+		}
+		if err := deparseInto(&seq.Ownership.SQL, seq.Ownership.Node); err != nil {
+			return nil, fmt.Errorf("error deparsing owner of sequence %s: %v", seq.QualifiedName(), err)
+		}
+	}
+	for i := range deparsed.Tables {
+		table := &deparsed.Tables[i]
+		if err := deparseInto(&table.SQL, table.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing table %s: %v", table.QualifiedName(), err)
+		}
+		table.ColumnAlterations = append([]Statement(nil), table.ColumnAlterations...)
+		for j := range table.ColumnAlterations {
+			alteration := &table.ColumnAlterations[j]
+			if err := deparseInto(&alteration.SQL, alteration.Node); err != nil {
+				return nil, fmt.Errorf("error deparsing column alteration of %s: %v", table.QualifiedName(), err)
+			}
+		}
+		table.AddedConstraints = append([]ConstraintDef(nil), table.AddedConstraints...)
+		for j := range table.AddedConstraints {
+			c := &table.AddedConstraints[j]
+			if err := deparseInto(&c.SQL, c.Stmt); err != nil {
+				return nil, fmt.Errorf("error deparsing constraint %s on %s: %v", c.Name, table.QualifiedName(), err)
+			}
+		}
+	}
+	for i := range deparsed.Views {
+		view := &deparsed.Views[i]
+		if err := deparseInto(&view.SQL, view.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing view %s: %v", view.QualifiedName(), err)
+		}
+	}
+	for i := range deparsed.Functions {
+		fn := &deparsed.Functions[i]
+		if err := deparseInto(&fn.SQL, fn.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing function %s: %v", fn.Signature(), err)
+		}
+	}
+	for i := range deparsed.Triggers {
+		trigger := &deparsed.Triggers[i]
+		if err := deparseInto(&trigger.SQL, trigger.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing trigger %s on %s: %v", trigger.Name, trigger.TableName(), err)
+		}
+	}
+	for i := range deparsed.ForeignKeys {
+		fk := &deparsed.ForeignKeys[i]
+		if err := deparseInto(&fk.SQL, fk.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing foreign key %s: %v", fk.Name, err)
+		}
+	}
+	for i := range deparsed.Indexes {
+		index := &deparsed.Indexes[i]
+		if err := deparseInto(&index.SQL, index.Stmt); err != nil {
+			return nil, fmt.Errorf("error deparsing index %s: %v", index.Name, err)
+		}
+	}
+	return &deparsed, nil
+}
+
+func deparseInto(sql *string, stmt *pg_query.Node) error {
+	if stmt == nil {
+		return nil
+	}
+	deparsed, err := Deparse(stmt)
+	if err != nil {
+		return err
+	}
+	*sql = deparsed
+	return nil
+}
@@ -0,0 +1,99 @@
+package schema
+
+import "strings"
+
+// FilterByPrefix returns a new Schema holding the tables in the public
+// schema whose name starts with prefix followed by an underscore, plus the
+// whitelisted tables, along with everything Filter keeps for them.
+// Whitelist entries are table names, qualified with a schema unless the
+// table is in the public schema.
+//
+// Deprecated: use Filter with a Selector including PrefixPattern(prefix).
+func (s *Schema) FilterByPrefix(prefix string, whitelist []string) *Schema {
+	sel := &Selector{Include: []Pattern{{text: PrefixPattern(prefix), glob: PrefixPattern(prefix)}}}
+	for _, name := range whitelist {
+		sel.Include = append(sel.Include, Pattern{text: name, glob: qualifyName(name)})
+	}
+	return s.Filter(sel)
+}
+
+// Filter returns a new Schema holding the tables selected by sel, their
+// indexes and triggers, the user-defined types and sequences their columns
+// use, the views over them, the functions their defaults, checks, triggers
+// and views call and the foreign keys that touch any of them.
+func (s *Schema) Filter(sel *Selector) *Schema {
+	filtered := &Schema{}
+	tableNames := make(map[string]bool)
+	for _, table := range s.Tables {
+		if sel.Match(table.QualifiedName()) {
+			filtered.Tables = append(filtered.Tables, table)
+			tableNames[table.QualifiedName()] = true
+		}
+	}
+
+	filtered.Views = s.ViewsOver(filtered.Tables)
+	filtered.Triggers = s.TriggersOn(filtered.Tables)
+	filtered.Functions = s.UsedFunctions(filtered.Tables, filtered.Views, filtered.Triggers)
+	filtered.Enums = s.UsedEnums(filtered.Tables, filtered.Functions)
+	filtered.Domains = s.UsedDomains(filtered.Tables, filtered.Functions)
+	filtered.CompositeTypes = s.UsedCompositeTypes(filtered.Tables, filtered.Functions)
+	filtered.RangeTypes = s.UsedRangeTypes(filtered.Tables, filtered.Functions)
+	filtered.Sequences = s.UsedSequences(filtered.Tables, filtered.Functions)
+
+	for _, index := range s.Indexes {
+		// Materialized views have indexes too
+		if tableNames[index.TableName()] || filtered.View(index.TableName()) != nil {
+			filtered.Indexes = append(filtered.Indexes, index)
+		}
+	}
+
+	// Only include FK if either source or target is in our filtered tables
+	for _, fk := range s.ForeignKeys {
+		if tableNames[fk.FromTable()] || tableNames[fk.ToTable()] {
+			filtered.ForeignKeys = append(filtered.ForeignKeys, fk)
+		}
+	}
+
+	return filtered
+}
+
+// qualifyName adds the public schema to a table name that has none.
+func qualifyName(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return DefaultSchema + "." + name
+}
+
+// UsedEnums returns the enum types used by the columns of tables or by
+// functions, directly or through another user-defined type such as a
+// domain, in the order they are first used.
+func (s *Schema) UsedEnums(tables []TableDef, functions []FunctionDef) []EnumDef {
+	var usedEnums []EnumDef
+	for _, name := range s.usedTypes(tables, functions) {
+		if enum := s.Enum(name); enum != nil {
+			usedEnums = append(usedEnums, *enum)
+		}
+	}
+	return usedEnums
+}
+
+// ColumnEnum returns the enum type of col, or nil if col is not an enum.
+// It relies on col.UserType, which Parse and ParseRegex resolve by
+// qualified name, so enums of the same name in different schemas are told
+// apart.
+func (s *Schema) ColumnEnum(col ColumnDef) *EnumDef {
+	if col.UserType == "" {
+		return nil
+	}
+	return s.Enum(col.UserType)
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	const dump = `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+CREATE TABLE public.submissions_files (
+    id bigint NOT NULL
+);
+
+CREATE TABLE public.submissions_notes (
+    id bigint NOT NULL
+);
+
+CREATE TABLE billing.invoices (
+    id bigint NOT NULL
+);
+
+CREATE TABLE billing.invoice_events (
+    id bigint NOT NULL
+);
+
+CREATE TABLE ledger.entry_events (
+    id bigint NOT NULL
+);
+`
+	s, err := ParseRegex(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseRegex() error = %v", err)
+	}
+	tables := func(filtered *Schema) []string {
+		var names []string
+		for _, table := range filtered.Tables {
+			names = append(names, table.QualifiedName())
+		}
+		return names
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{
+			name:    "prefix",
+			include: []string{PrefixPattern("submissions")},
+			want:    []string{"public.submissions_files", "public.submissions_notes"},
+		},
+		{
+			name:    "unqualified name",
+			include: []string{"users"},
+			want:    []string{"public.users"},
+		},
+		{
+			name:    "schema glob",
+			include: []string{"billing.*"},
+			want:    []string{"billing.invoices", "billing.invoice_events"},
+		},
+		{
+			name:    "regex",
+			include: []string{`/^(billing|ledger)\..*_events$/`},
+			want:    []string{"billing.invoice_events", "ledger.entry_events"},
+		},
+		{
+			name:    "exclude",
+			include: []string{"*.*"},
+			exclude: []string{"public.submissions_*", "/_events$/"},
+			want:    []string{"public.users", "billing.invoices"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := NewSelector(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("NewSelector() error = %v", err)
+			}
+			if got := tables(s.Filter(sel)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() tables = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("FilterByPrefix", func(t *testing.T) {
+		got := tables(s.FilterByPrefix("submissions", []string{"users", "billing.invoices"}))
+		want := []string{"public.users", "public.submissions_files", "public.submissions_notes", "billing.invoices"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FilterByPrefix() tables = %q, want %q", got, want)
+		}
+	})
+
+	if _, err := NewSelector([]string{"/(/"}, nil); err == nil {
+		t.Errorf("NewSelector() of an invalid regex error = nil")
+	}
+}
@@ -0,0 +1,550 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// parameterModes maps the modes of function parameters other than IN to
+// their SQL keywords.
+var parameterModes = map[pg_query.FunctionParameterMode]string{
+	pg_query.FunctionParameterMode_FUNC_PARAM_OUT:      "OUT",
+	pg_query.FunctionParameterMode_FUNC_PARAM_INOUT:    "INOUT",
+	pg_query.FunctionParameterMode_FUNC_PARAM_VARIADIC: "VARIADIC",
+}
+
+// QualifiedName returns the function name as "schema.name".
+func (f FunctionDef) QualifiedName() string {
+	return QualifiedName(f.Schema, f.Name)
+}
+
+// Signature returns the function as "schema.name(arguments)", which tells
+// overloads apart.
+func (f FunctionDef) Signature() string {
+	return fmt.Sprintf("%s(%s)", f.QualifiedName(), f.Arguments)
+}
+
+// TableName returns the qualified name of the table the trigger is on.
+func (t TriggerDef) TableName() string {
+	return QualifiedName(t.Schema, t.Table)
+}
+
+// Function returns the function with the given signature, as returned by
+// FunctionDef.Signature, or nil if the schema has no such function.
+func (s *Schema) Function(signature string) *FunctionDef {
+	for i := range s.Functions {
+		if s.Functions[i].Signature() == signature {
+			return &s.Functions[i]
+		}
+	}
+	return nil
+}
+
+// FunctionsNamed returns the functions a function name, as written in a
+// call, refers to when looked up along searchPath: every overload in the
+// first schema that has a function of that name. Built-in functions are
+// not in s, so a name that only names one returns nothing.
+func (s *Schema) FunctionsNamed(name string, searchPath []string) []FunctionDef {
+	name = strings.ReplaceAll(name, `"`, "")
+	schemas := searchPath
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schemas = []string{name[:i]}
+		name = name[i+1:]
+	}
+	for _, schema := range schemas {
+		var functions []FunctionDef
+		for _, fn := range s.Functions {
+			if fn.Schema == schema && fn.Name == name {
+				functions = append(functions, fn)
+			}
+		}
+		if len(functions) > 0 {
+			return functions
+		}
+	}
+	return nil
+}
+
+// UsedFunctions returns the functions that the defaults and check
+// constraints of tables, the queries of views, triggers and the domains
+// the tables and those functions use call, along with the functions those
+// call in turn, in the order they are first used.
+func (s *Schema) UsedFunctions(tables []TableDef, views []ViewDef, triggers []TriggerDef) []FunctionDef {
+	var used []FunctionDef
+	seen := make(map[string]bool)
+	var add func(name string, searchPath []string)
+	add = func(name string, searchPath []string) {
+		for _, fn := range s.FunctionsNamed(name, searchPath) {
+			if seen[fn.Signature()] {
+				continue
+			}
+			seen[fn.Signature()] = true
+			used = append(used, fn)
+			for _, called := range fn.Functions {
+				add(called, fn.SearchPath)
+			}
+		}
+	}
+	for _, table := range tables {
+		for _, col := range table.Columns {
+			for _, name := range col.DefaultFunctions {
+				add(name, table.SearchPath)
+			}
+		}
+		for _, name := range table.CheckFunctions {
+			add(name, table.SearchPath)
+		}
+	}
+	for _, view := range views {
+		for _, name := range view.Functions {
+			add(name, view.SearchPath)
+		}
+	}
+	for _, trigger := range triggers {
+		add(trigger.Function, trigger.SearchPath)
+	}
+	// The functions a domain calls can use other domains in turn
+	for n := -1; n != len(used); {
+		n = len(used)
+		for _, domain := range s.UsedDomains(tables, used) {
+			for _, name := range domain.Functions {
+				add(name, domain.SearchPath)
+			}
+		}
+	}
+	return used
+}
+
+// TriggersOn returns the triggers on tables, in the order s defines them.
+func (s *Schema) TriggersOn(tables []TableDef) []TriggerDef {
+	names := make(map[string]bool)
+	for _, table := range tables {
+		names[table.QualifiedName()] = true
+	}
+	var triggers []TriggerDef
+	for _, trigger := range s.Triggers {
+		if names[trigger.TableName()] {
+			triggers = append(triggers, trigger)
+		}
+	}
+	return triggers
+}
+
+// ParseCreateFunction converts a CREATE FUNCTION or CREATE PROCEDURE
+// statement into a FunctionDef. The SQL field and the objects the body
+// uses are left empty.
+func ParseCreateFunction(stmt *pg_query.CreateFunctionStmt) FunctionDef {
+	fn := FunctionDef{
+		SearchPath: DefaultSearchPath,
+		Stmt:       &pg_query.Node{Node: &pg_query.Node_CreateFunctionStmt{CreateFunctionStmt: stmt}},
+	}
+	fn.Schema, fn.Name = splitTypeName(stringList(stmt.GetFuncname()))
+
+	var arguments, columns []string
+	for _, node := range stmt.GetParameters() {
+		param := node.GetFunctionParameter()
+		typ, _, _ := formatTypeName(param.GetArgType())
+		if param.GetName() != "" {
+			typ = QuoteIdent(param.GetName()) + " " + typ
+		}
+		if param.GetMode() == pg_query.FunctionParameterMode_FUNC_PARAM_TABLE {
+			columns = append(columns, typ)
+			continue
+		}
+		if mode := parameterModes[param.GetMode()]; mode != "" {
+			typ = mode + " " + typ
+		}
+		if def := defaultValue(param.GetDefexpr()); def != "" {
+			typ += " DEFAULT " + def
+		}
+		arguments = append(arguments, typ)
+	}
+	fn.Arguments = strings.Join(arguments, ", ")
+
+	switch {
+	case len(columns) > 0:
+		fn.ReturnType = fmt.Sprintf("TABLE(%s)", strings.Join(columns, ", "))
+	case stmt.GetReturnType() != nil:
+		fn.ReturnType, _, _ = formatTypeName(stmt.GetReturnType())
+		if stmt.GetReturnType().GetSetof() {
+			fn.ReturnType = "SETOF " + fn.ReturnType
+		}
+	}
+
+	for _, option := range stmt.GetOptions() {
+		defElem := option.GetDefElem()
+		switch defElem.GetDefname() {
+		case "language":
+			fn.Language = defElem.GetArg().GetString_().GetSval()
+		case "as":
+			if body := stringList(defElem.GetArg().GetList().GetItems()); len(body) > 0 {
+				fn.Body = body[0]
+			}
+		case "set":
+			if path, ok := searchPathOf(defElem.GetArg()); ok {
+				fn.SearchPath = path
+			}
+		}
+	}
+	if stmt.GetSqlBody() != nil && fn.Language == "" {
+		fn.Language = "sql"
+	}
+	return fn
+}
+
+// ParseCreateTrigger converts a CREATE TRIGGER statement into a
+// TriggerDef. The SQL field is left empty.
+func ParseCreateTrigger(stmt *pg_query.CreateTrigStmt) TriggerDef {
+	trigger := TriggerDef{
+		Name:     stmt.GetTrigname(),
+		Schema:   DefaultSchema,
+		Table:    stmt.GetRelation().GetRelname(),
+		Function: strings.Join(stringList(stmt.GetFuncname()), "."),
+		Stmt:     &pg_query.Node{Node: &pg_query.Node_CreateTrigStmt{CreateTrigStmt: stmt}},
+	}
+	if schema := stmt.GetRelation().GetSchemaname(); schema != "" {
+		trigger.Schema = schema
+	}
+	return trigger
+}
+
+// findFunctionReferences fills in the tables, sequences, types and
+// functions each function of s uses. Functions usually come before the
+// tables they use in a dump, so this runs once the whole dump is parsed.
+//
+// The arguments, result and SQL body of a function are taken from its
+// parse tree. Bodies written as strings are parsed with pg_query when the
+// language is sql, and PL/pgSQL bodies are parsed with
+// pg_query.ParsePlPgSqlToJSON, whose output holds the SQL of each
+// statement and expression of the body and the types of its variables.
+// Queries run with EXECUTE are built at run time and cannot be followed.
+func (s *Schema) findFunctionReferences() {
+	for i := range s.Functions {
+		fn := &s.Functions[i]
+		create := fn.Stmt.GetCreateFunctionStmt()
+		if create == nil {
+			continue
+		}
+		for _, node := range create.GetParameters() {
+			s.addFunctionType(fn, node.GetFunctionParameter().GetArgType())
+		}
+		s.addFunctionType(fn, create.GetReturnType())
+
+		switch {
+		case create.GetSqlBody() != nil:
+			s.addFunctionReferences(fn, create.GetSqlBody())
+		case strings.EqualFold(fn.Language, "sql"):
+			if result, err := pg_query.Parse(fn.Body); err == nil {
+				for _, stmt := range result.Stmts {
+					s.addFunctionReferences(fn, stmt.GetStmt())
+				}
+			}
+		case strings.EqualFold(fn.Language, "plpgsql"):
+			s.addPlPgSQLReferences(fn)
+		}
+	}
+}
+
+// addPlPgSQLReferences adds what the PL/pgSQL body of fn uses to fn.
+func (s *Schema) addPlPgSQLReferences(fn *FunctionDef) {
+	tree, err := pg_query.ParsePlPgSqlToJSON(fn.SQL)
+	if err != nil {
+		return
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(tree), &decoded); err != nil {
+		return
+	}
+
+	var queries, types []string
+	collectPlPgSQL(decoded, &queries, &types)
+	for _, query := range queries {
+		if result := parsePlPgSQLExpr(query); result != nil {
+			for _, stmt := range result.Stmts {
+				s.addFunctionReferences(fn, stmt.GetStmt())
+			}
+		}
+	}
+	for _, typ := range types {
+		// Drop the %TYPE or %ROWTYPE of a declaration like orders%ROWTYPE
+		typ, _, _ = strings.Cut(typ, "%")
+		base, _ := splitTypeText(typ)
+		s.addFunctionTypeName(fn, base)
+	}
+}
+
+// collectPlPgSQL gathers the SQL text of every PLpgSQL_expr and the name
+// of every PLpgSQL_type in the JSON tree of a PL/pgSQL function.
+func collectPlPgSQL(tree interface{}, queries, types *[]string) {
+	switch v := tree.(type) {
+	case map[string]interface{}:
+		// Walk the keys in order, so the results do not depend on the
+		// map's iteration order
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := v[key]
+			if text, ok := value.(string); ok {
+				switch key {
+				case "query":
+					*queries = append(*queries, text)
+				case "typname":
+					*types = append(*types, text)
+				}
+				continue
+			}
+			collectPlPgSQL(value, queries, types)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectPlPgSQL(item, queries, types)
+		}
+	}
+}
+
+// parsePlPgSQLExpr parses the SQL of a PL/pgSQL statement or expression.
+// Statements parse as they are, but expressions such as "x > 0" and
+// assignments such as "total := total + 1" have no SELECT of their own, so
+// one is added. It returns nil if none of these parse.
+func parsePlPgSQLExpr(query string) *pg_query.ParseResult {
+	candidates := []string{query, "SELECT " + query}
+	if i := strings.Index(query, ":="); i >= 0 {
+		candidates = append(candidates, "SELECT "+query[i+len(":="):])
+	}
+	for _, candidate := range candidates {
+		if result, err := pg_query.Parse(candidate); err == nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// addFunctionReferences adds the tables, sequences, types and functions
+// used under node to fn.
+func (s *Schema) addFunctionReferences(fn *FunctionDef, node *pg_query.Node) {
+	walk(node, func(node *pg_query.Node) bool {
+		switch {
+		case node.GetRangeVar() != nil:
+			rv := node.GetRangeVar()
+			s.addFunctionTable(fn, rv.GetSchemaname(), rv.GetRelname())
+		case node.GetFuncCall() != nil:
+			call := node.GetFuncCall()
+			fn.Functions = appendUnique(fn.Functions, strings.Join(stringList(call.GetFuncname()), "."))
+			s.addFunctionSequence(fn, sequenceArgumentName(call))
+		case node.GetTypeCast() != nil:
+			s.addFunctionType(fn, node.GetTypeCast().GetTypeName())
+		}
+		return true
+	})
+}
+
+// addFunctionTable adds the table or view a relation name in the body of
+// fn refers to, if s defines it.
+func (s *Schema) addFunctionTable(fn *FunctionDef, schema, name string) {
+	schemas := fn.SearchPath
+	if schema != "" {
+		schemas = []string{schema}
+	}
+	for _, schema := range schemas {
+		if qualified := QualifiedName(schema, name); s.Table(qualified) != nil || s.View(qualified) != nil {
+			fn.Tables = appendUnique(fn.Tables, qualified)
+			return
+		}
+	}
+}
+
+// addFunctionSequence adds the sequence a sequence name passed to
+// nextval, currval or setval in the body of fn refers to, if s defines it.
+func (s *Schema) addFunctionSequence(fn *FunctionDef, name string) {
+	if name == "" {
+		return
+	}
+	schemas := fn.SearchPath
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schemas = []string{name[:i]}
+		name = name[i+1:]
+	}
+	for _, schema := range schemas {
+		if qualified := QualifiedName(schema, name); s.Sequence(qualified) != nil {
+			fn.Sequences = appendUnique(fn.Sequences, qualified)
+			return
+		}
+	}
+}
+
+// addFunctionType adds the user-defined type, or table row type, that
+// typeName refers to, if any.
+func (s *Schema) addFunctionType(fn *FunctionDef, typeName *pg_query.TypeName) {
+	if _, base, _ := formatTypeName(typeName); base != "" {
+		s.addFunctionTypeName(fn, base)
+	}
+}
+
+// addFunctionTypeName adds the user-defined type, or table row type, that
+// the base type name refers to along the search path of fn, if any.
+func (s *Schema) addFunctionTypeName(fn *FunctionDef, name string) {
+	if userType := s.ResolveType(name, fn.SearchPath); userType != "" {
+		fn.Types = appendUnique(fn.Types, userType)
+		return
+	}
+	schemas := fn.SearchPath
+	name = strings.ReplaceAll(name, `"`, "")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schemas = []string{name[:i]}
+		name = name[i+1:]
+	}
+	for _, schema := range schemas {
+		if qualified := QualifiedName(schema, name); s.Table(qualified) != nil {
+			fn.Types = appendUnique(fn.Types, qualified)
+			return
+		}
+	}
+}
+
+var (
+	functionPattern       = regexp.MustCompile(`^CREATE (?:OR REPLACE )?(?:FUNCTION|PROCEDURE) "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"?\((.*)$`)
+	functionOptionPattern = regexp.MustCompile(`^\s+(LANGUAGE|SET search_path (?:TO|=)) (.+?);?$`)
+	dollarQuotePattern    = regexp.MustCompile(`\bAS (\$[a-zA-Z0-9_]*\$)`)
+	triggerPattern        = regexp.MustCompile(`^CREATE (?:CONSTRAINT )?TRIGGER "?([a-zA-Z0-9_]+)"? .*? ON (?:ONLY )?"?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"? .*\bEXECUTE (?:FUNCTION|PROCEDURE) ([a-zA-Z0-9_."]+)\(`)
+	relationUsePattern    = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|INTO|UPDATE|TABLE)\s+(?:ONLY\s+)?"?([a-zA-Z_][a-zA-Z0-9_]*)"?(?:\."?([a-zA-Z_][a-zA-Z0-9_]*)"?)?`)
+	sqlBodyPattern        = regexp.MustCompile(`\b(?:BEGIN ATOMIC|RETURN)\b`)
+	typeCastPattern       = regexp.MustCompile(`::"?([a-zA-Z_][a-zA-Z0-9_]*)"?(?:\."?([a-zA-Z_][a-zA-Z0-9_]*)"?)?`)
+)
+
+// parseFunctionsRegex finds the CREATE FUNCTION and CREATE PROCEDURE
+// blocks pg_dump writes, reading the body between its dollar quotes, and
+// the single-line CREATE TRIGGER statements.
+func parseFunctionsRegex(sqlContent string) ([]FunctionDef, []TriggerDef) {
+	var functions []FunctionDef
+	var triggers []TriggerDef
+	var current *FunctionDef
+	searchPath := DefaultSearchPath
+	for i, line := range strings.Split(sqlContent, "\n") {
+		pos := Position{Line: i + 1, Column: 1}
+		if current == nil {
+			if path, ok := searchPathRegex(line); ok {
+				searchPath = path
+			}
+			if matches := triggerPattern.FindStringSubmatch(line); matches != nil {
+				triggers = append(triggers, TriggerDef{
+					Name:       matches[1],
+					Schema:     matches[2],
+					Table:      matches[3],
+					Function:   strings.ReplaceAll(matches[4], `"`, ""),
+					SearchPath: searchPath,
+					SQL:        line + "\n",
+					Pos:        pos,
+				})
+				continue
+			}
+			matches := functionPattern.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			current = &FunctionDef{Schema: matches[1], Name: matches[2], SearchPath: DefaultSearchPath, DumpSearchPath: searchPath, Pos: pos}
+			if arguments, rest, ok := splitParens(matches[3]); ok {
+				current.Arguments = arguments
+				if returns := strings.TrimSpace(rest); strings.HasPrefix(returns, "RETURNS ") {
+					current.ReturnType = strings.TrimPrefix(returns, "RETURNS ")
+				}
+			}
+		}
+
+		current.SQL += line + "\n"
+		// pg_dump writes the options before the body
+		if matches := functionOptionPattern.FindStringSubmatch(line); matches != nil && !dollarQuotePattern.MatchString(current.SQL) {
+			if matches[1] == "LANGUAGE" {
+				// Other options, such as IMMUTABLE, may follow on the line
+				current.Language = strings.Fields(matches[2])[0]
+			} else {
+				current.SearchPath = parseSearchPath(strings.ReplaceAll(matches[2], "'", ""))
+			}
+		}
+		if body, ok := functionBodyRegex(current.SQL); ok {
+			current.Body = body
+			functions = append(functions, *current)
+			current = nil
+		}
+	}
+	return functions, triggers
+}
+
+// functionBodyRegex returns the body of a CREATE FUNCTION block written
+// between dollar quotes, and whether the block is complete. A SQL-standard
+// body, a BEGIN ATOMIC block or a RETURN clause, ends at the first line
+// ending in a semicolon, or at "END;" for BEGIN ATOMIC, and gives "".
+func functionBodyRegex(sql string) (string, bool) {
+	trimmed := strings.TrimSpace(sql)
+	if loc := dollarQuotePattern.FindStringSubmatchIndex(sql); loc != nil {
+		tag := sql[loc[2]:loc[3]]
+		body := sql[loc[3]:]
+		end := strings.Index(body, tag)
+		if end < 0 || !strings.HasSuffix(trimmed, ";") {
+			return "", false
+		}
+		return body[:end], true
+	}
+	if strings.Contains(sql, "BEGIN ATOMIC") {
+		return "", strings.HasSuffix(trimmed, "END;")
+	}
+	return "", strings.HasSuffix(trimmed, ";")
+}
+
+// findFunctionReferencesRegex is findFunctionReferences for functions
+// read by the regex backend. Relations are the names that follow FROM,
+// JOIN, INTO, UPDATE or TABLE in the body, types the argument and result
+// types and the targets of casts, and functions and sequences are picked
+// out as they are for column defaults.
+func (s *Schema) findFunctionReferencesRegex() {
+	for i := range s.Functions {
+		fn := &s.Functions[i]
+		body := fn.Body
+		if body == "" {
+			if loc := sqlBodyPattern.FindStringIndex(fn.SQL); loc != nil {
+				body = fn.SQL[loc[0]:]
+			}
+		}
+		code := stringLiteralPattern.ReplaceAllString(body, "''")
+		for _, matches := range relationUsePattern.FindAllStringSubmatch(code, -1) {
+			if matches[2] == "" {
+				s.addFunctionTable(fn, "", matches[1])
+			} else {
+				s.addFunctionTable(fn, matches[1], matches[2])
+			}
+		}
+		for _, matches := range sequenceCallPattern.FindAllStringSubmatch(body, -1) {
+			s.addFunctionSequence(fn, strings.ReplaceAll(matches[1], `"`, ""))
+		}
+		fn.Functions = functionsRegex(body)
+
+		// An argument is written as [mode] [name] type [DEFAULT value], so
+		// try the type with and without its first word
+		var types []string
+		for _, argument := range splitTopLevel(fn.Arguments) {
+			if i := strings.Index(argument, " DEFAULT "); i >= 0 {
+				argument = argument[:i]
+			}
+			types = append(types, argument)
+			if _, rest, ok := strings.Cut(argument, " "); ok {
+				types = append(types, rest)
+			}
+		}
+		types = append(types, strings.TrimPrefix(fn.ReturnType, "SETOF "))
+		for _, matches := range typeCastPattern.FindAllStringSubmatch(code, -1) {
+			types = append(types, strings.Trim(matches[1]+"."+matches[2], "."))
+		}
+		for _, typ := range types {
+			if base, _ := splitTypeText(typ); base != "" {
+				s.addFunctionTypeName(fn, base)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFunctionTables(t *testing.T) {
+	const tables = `CREATE TABLE public.t (
+    id bigint NOT NULL,
+    n integer
+);
+
+CREATE TABLE public.u (
+    id bigint NOT NULL
+);
+
+CREATE TABLE public.audit (
+    id bigint NOT NULL,
+    at timestamp with time zone
+);
+`
+	tests := []struct {
+		name   string
+		sql    string
+		tables []string
+	}{
+		{
+			name: "sql insert",
+			sql: `CREATE FUNCTION public.log_it() RETURNS void
+    LANGUAGE sql
+    AS $$ INSERT INTO public.audit (at) VALUES (now()) $$;`,
+			tables: []string{"public.audit"},
+		},
+		{
+			name: "sql update",
+			sql: `CREATE FUNCTION public.bump() RETURNS void
+    LANGUAGE sql
+    AS $$ UPDATE public.t SET n = n + 1 $$;`,
+			tables: []string{"public.t"},
+		},
+		{
+			name: "sql delete",
+			sql: `CREATE FUNCTION public.purge() RETURNS void
+    LANGUAGE sql
+    AS $$ DELETE FROM public.u WHERE id IN (SELECT id FROM public.t) $$;`,
+			tables: []string{"public.u", "public.t"},
+		},
+		{
+			name: "plpgsql insert",
+			sql: `CREATE FUNCTION public.audit_trigger() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    INSERT INTO public.audit (at) VALUES (now());
+    RETURN NEW;
+END;
+$$;`,
+			tables: []string{"public.audit"},
+		},
+		{
+			name: "plpgsql update",
+			sql: `CREATE FUNCTION public.bump_all() RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    UPDATE public.t SET n = n + 1;
+END;
+$$;`,
+			tables: []string{"public.t"},
+		},
+		{
+			name: "plpgsql delete",
+			sql: `CREATE FUNCTION public.purge_all() RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    DELETE FROM public.u;
+END;
+$$;`,
+			tables: []string{"public.u"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(strings.NewReader(tables + "\n" + tt.sql + "\n"))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(s.Functions) != 1 {
+				t.Fatalf("functions = %d, want 1", len(s.Functions))
+			}
+			if got := s.Functions[0].Tables; !reflect.DeepEqual(got, tt.tables) {
+				t.Errorf("Tables = %q, want %q", got, tt.tables)
+			}
+		})
+	}
+}
+
+func TestDomainFunctions(t *testing.T) {
+	const dump = `CREATE FUNCTION public.valid_email(text) RETURNS boolean
+    LANGUAGE sql IMMUTABLE
+    AS $$ SELECT $1 ~ '@' $$;
+
+CREATE FUNCTION public.unused() RETURNS boolean
+    LANGUAGE sql IMMUTABLE
+    AS $$ SELECT true $$;
+
+CREATE DOMAIN public.email AS text
+	CONSTRAINT email_check CHECK (public.valid_email(VALUE));
+
+CREATE DOMAIN public.short AS text
+	CONSTRAINT short_check CHECK (length(VALUE) < 10);
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email public.email,
+    nick public.short
+);
+`
+	s, err := ParseRegex(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseRegex() error = %v", err)
+	}
+	used := s.UsedFunctions(s.Tables, nil, nil)
+	var names []string
+	for _, fn := range used {
+		names = append(names, fn.Signature())
+	}
+	if want := []string{"public.valid_email(text)"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("UsedFunctions() = %q, want %q", names, want)
+	}
+
+	filtered := &Schema{Tables: s.Tables, Functions: used, Domains: s.UsedDomains(s.Tables, used)}
+	var out bytes.Buffer
+	if err := filtered.WriteSQL(&out); err != nil {
+		t.Fatalf("WriteSQL() error = %v", err)
+	}
+	sql := out.String()
+	short := strings.Index(sql, "CREATE DOMAIN public.short")
+	function := strings.Index(sql, "CREATE FUNCTION public.valid_email")
+	email := strings.Index(sql, "CREATE DOMAIN public.email")
+	table := strings.Index(sql, "CREATE TABLE public.users")
+	if short < 0 || function < 0 || email < 0 || table < 0 || !(short < function && function < email && email < table) {
+		t.Errorf("WriteSQL() writes the domain calling a function before it:\n%s", sql)
+	}
+}
@@ -0,0 +1,329 @@
+package schema
+
+import "strings"
+
+// RelationshipSource says how a relationship between two tables was found.
+type RelationshipSource string
+
+const (
+	// Declared relationships come from FOREIGN KEY constraints.
+	Declared RelationshipSource = "foreign key"
+	// Guessed relationships come from column names: a column named
+	// <name>_id is taken to point at the id of the table <name> or
+	// <name>s. They are only added when no foreign key covers the column.
+	Guessed RelationshipSource = "guessed"
+)
+
+// Relationship is a directed edge from a referencing table to the table it
+// references. Tables are qualified names.
+type Relationship struct {
+	From       string
+	Columns    []string
+	To         string
+	RefColumns []string
+	// ForeignKey is the constraint name of a declared relationship.
+	ForeignKey string
+	// Options holds the clauses of a declared relationship's foreign key
+	// that differ from the defaults, as returned by ForeignKeyDef.Options.
+	Options string
+	Source  RelationshipSource
+}
+
+// ObjectKind is the kind of object a dependency starts from.
+type ObjectKind string
+
+const (
+	// TableObject dependencies come from the defaults, check constraints
+	// and triggers of a table.
+	TableObject ObjectKind = "table"
+	// ViewObject and MaterializedViewObject dependencies come from the
+	// query of a view.
+	ViewObject             ObjectKind = "view"
+	MaterializedViewObject ObjectKind = "materialized view"
+	// FunctionObject dependencies come from the signature and body of a
+	// function.
+	FunctionObject ObjectKind = "function"
+)
+
+// Dependency is a directed edge from an object to an object it needs: from
+// a view to a table or view its query reads from or a function it calls,
+// from a function to a table, sequence, type or function it uses, or from
+// a table to a function its defaults, check constraints or triggers call.
+// Names are qualified, and functions are named by their Signature.
+type Dependency struct {
+	From string
+	To   string
+	// Columns lists the columns of To that From uses, as far as they are
+	// known.
+	Columns []string
+	Kind    ObjectKind
+}
+
+// Graph holds the relationships between the tables of a schema, and the
+// dependencies of its views, functions and tables on the objects they
+// use.
+type Graph struct {
+	Relationships []Relationship
+	Dependencies  []Dependency
+	// Kinds maps the names of the tables, views and functions of the
+	// schema to their kind.
+	Kinds map[string]ObjectKind
+}
+
+// NewGraph builds the relationship graph of s from its foreign keys,
+// views, functions and triggers. When guess is true, relationships implied
+// by <name>_id column names are added as well and marked Guessed.
+func NewGraph(s *Schema, guess bool) *Graph {
+	g := &Graph{Kinds: make(map[string]ObjectKind)}
+	for _, table := range s.Tables {
+		g.Kinds[table.QualifiedName()] = TableObject
+	}
+	for _, fn := range s.Functions {
+		g.Kinds[fn.Signature()] = FunctionObject
+	}
+	for _, view := range s.Views {
+		kind := ViewObject
+		if view.Materialized {
+			kind = MaterializedViewObject
+		}
+		g.Kinds[view.QualifiedName()] = kind
+		for _, relation := range view.Relations {
+			dep := Dependency{From: view.QualifiedName(), To: relation, Kind: kind}
+			for _, column := range view.Columns {
+				if strings.HasPrefix(column, relation+".") {
+					dep.Columns = append(dep.Columns, strings.TrimPrefix(column, relation+"."))
+				}
+			}
+			g.Dependencies = append(g.Dependencies, dep)
+		}
+		g.addFunctionDependencies(s, view.QualifiedName(), kind, view.Functions, view.SearchPath)
+	}
+	for _, fn := range s.Functions {
+		for _, names := range [][]string{fn.Tables, fn.Sequences, fn.Types} {
+			for _, name := range names {
+				g.Dependencies = append(g.Dependencies, Dependency{From: fn.Signature(), To: name, Kind: FunctionObject})
+			}
+		}
+		g.addFunctionDependencies(s, fn.Signature(), FunctionObject, fn.Functions, fn.SearchPath)
+	}
+	for _, table := range s.Tables {
+		var functions []string
+		for _, col := range table.Columns {
+			functions = append(functions, col.DefaultFunctions...)
+		}
+		functions = append(functions, table.CheckFunctions...)
+		for _, trigger := range s.TriggersOn([]TableDef{table}) {
+			functions = append(functions, trigger.Function)
+		}
+		g.addFunctionDependencies(s, table.QualifiedName(), TableObject, functions, table.SearchPath)
+	}
+
+	declared := make(map[string]bool)
+	for _, fk := range s.ForeignKeys {
+		g.Relationships = append(g.Relationships, Relationship{
+			From:       fk.FromTable(),
+			Columns:    fk.Columns,
+			To:         fk.ToTable(),
+			RefColumns: fk.RefColumns,
+			ForeignKey: fk.Name,
+			Options:    fk.Options(),
+			Source:     Declared,
+		})
+		for _, column := range fk.Columns {
+			declared[fk.FromTable()+"."+column] = true
+		}
+	}
+
+	if guess {
+		for _, rel := range guessRelationships(s) {
+			if !declared[rel.From+"."+rel.Columns[0]] {
+				g.Relationships = append(g.Relationships, rel)
+			}
+		}
+	}
+	return g
+}
+
+// addFunctionDependencies adds a dependency from the object from to each
+// function of s that the function names, called along searchPath, refer
+// to.
+func (g *Graph) addFunctionDependencies(s *Schema, from string, kind ObjectKind, names []string, searchPath []string) {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		for _, fn := range s.FunctionsNamed(name, searchPath) {
+			if !seen[fn.Signature()] && fn.Signature() != from {
+				seen[fn.Signature()] = true
+				g.Dependencies = append(g.Dependencies, Dependency{From: from, To: fn.Signature(), Kind: kind})
+			}
+		}
+	}
+}
+
+// guessRelationships links every <name>_id column to the tables named
+// <name> or <name>s.
+func guessRelationships(s *Schema) []Relationship {
+	var relationships []Relationship
+	for _, table := range s.Tables {
+		for _, col := range table.Columns {
+			columnName := strings.ToLower(col.Name)
+			if !strings.HasSuffix(columnName, "_id") {
+				continue
+			}
+
+			// Try both singular and plural forms
+			singularName := strings.TrimSuffix(columnName, "_id")
+			pluralName := singularName + "s"
+
+			for _, other := range s.Tables {
+				otherName := strings.ToLower(other.Name)
+				if otherName != singularName && otherName != pluralName {
+					continue
+				}
+				relationships = append(relationships, Relationship{
+					From:       table.QualifiedName(),
+					Columns:    []string{col.Name},
+					To:         other.QualifiedName(),
+					RefColumns: []string{"id"},
+					Source:     Guessed,
+				})
+			}
+		}
+	}
+	return relationships
+}
+
+// References returns the relationships from table to other tables.
+func (g *Graph) References(table string) []Relationship {
+	var relationships []Relationship
+	for _, rel := range g.Relationships {
+		if rel.From == table {
+			relationships = append(relationships, rel)
+		}
+	}
+	return relationships
+}
+
+// ReferencedBy returns the relationships from other tables to table.
+func (g *Graph) ReferencedBy(table string) []Relationship {
+	var relationships []Relationship
+	for _, rel := range g.Relationships {
+		if rel.To == table {
+			relationships = append(relationships, rel)
+		}
+	}
+	return relationships
+}
+
+// Closure returns the tables reachable from tables by following
+// relationships outward, from referencing table to referenced table, for
+// up to depth hops. A negative depth follows them until no new tables are
+// found. When inward is true, relationships are also followed from
+// referenced table to referencing table. The starting tables are not
+// included in the result; the rest are returned in the order they were
+// reached.
+func (g *Graph) Closure(tables []string, depth int, inward bool) []string {
+	seen := make(map[string]bool)
+	for _, table := range tables {
+		seen[table] = true
+	}
+
+	var reached []string
+	frontier := tables
+	for hop := 0; len(frontier) > 0 && (depth < 0 || hop < depth); hop++ {
+		inFrontier := make(map[string]bool)
+		for _, table := range frontier {
+			inFrontier[table] = true
+		}
+
+		var next []string
+		visit := func(table string) {
+			if !seen[table] {
+				seen[table] = true
+				next = append(next, table)
+			}
+		}
+		for _, rel := range g.Relationships {
+			if inFrontier[rel.From] {
+				visit(rel.To)
+			}
+			if inward && inFrontier[rel.To] {
+				visit(rel.From)
+			}
+		}
+		reached = append(reached, next...)
+		frontier = next
+	}
+	return reached
+}
+
+// Neighbours returns the qualified names of the tables that reference, or
+// are referenced by, any of tables, excluding tables themselves. Names are
+// returned in the order their relationships appear in the graph.
+func (g *Graph) Neighbours(tables []string) []string {
+	selected := make(map[string]bool)
+	for _, table := range tables {
+		selected[table] = true
+	}
+
+	seen := make(map[string]bool)
+	var neighbours []string
+	add := func(table string) {
+		if !selected[table] && !seen[table] {
+			seen[table] = true
+			neighbours = append(neighbours, table)
+		}
+	}
+	for _, rel := range g.Relationships {
+		if selected[rel.From] {
+			add(rel.To)
+		}
+		if selected[rel.To] {
+			add(rel.From)
+		}
+	}
+	return neighbours
+}
+
+// Dependents returns the names of the views and functions that depend on
+// any of names, directly or through other views and functions, in the
+// order they are reached. names themselves are not included, and neither
+// are the tables whose defaults, checks or triggers call a function.
+func (g *Graph) Dependents(names []string) []string {
+	return g.dependents(names, func(dep Dependency) bool { return dep.Kind != TableObject })
+}
+
+// ColumnDependents returns the qualified names of the views that use the
+// given column of table, along with the views and functions that depend on
+// those, in the order they are reached.
+func (g *Graph) ColumnDependents(table, column string) []string {
+	return g.dependents([]string{table}, func(dep Dependency) bool {
+		return dep.Kind != TableObject && (dep.To != table || contains(dep.Columns, column))
+	})
+}
+
+// dependents follows the dependencies that follow accepts backwards from
+// names to the objects that depend on them.
+func (g *Graph) dependents(names []string, follow func(Dependency) bool) []string {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		seen[name] = true
+	}
+
+	var reached []string
+	for frontier := names; len(frontier) > 0; {
+		inFrontier := make(map[string]bool)
+		for _, name := range frontier {
+			inFrontier[name] = true
+		}
+		var next []string
+		for _, dep := range g.Dependencies {
+			if inFrontier[dep.To] && !seen[dep.From] && follow(dep) {
+				seen[dep.From] = true
+				next = append(next, dep.From)
+			}
+		}
+		reached = append(reached, next...)
+		frontier = next
+	}
+	return reached
+}
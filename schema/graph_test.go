@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphForeignKeys(t *testing.T) {
+	const tables = `CREATE TABLE public.users (
+    id bigint NOT NULL,
+    org_id bigint NOT NULL
+);
+
+CREATE TABLE public.orgs (
+    id bigint NOT NULL
+);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.orgs
+    ADD CONSTRAINT orgs_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT fk_users_org FOREIGN KEY (org_id) REFERENCES public.orgs(id);
+`
+	tests := []struct {
+		name string
+		sql  string
+		// relationship is the edge from public.posts, written as
+		// "(columns) -> table(columns)"
+		relationship string
+	}{
+		{
+			name: "added constraint",
+			sql: `CREATE TABLE public.posts (
+    id bigint NOT NULL,
+    user_id bigint
+);
+
+ALTER TABLE ONLY public.posts
+    ADD CONSTRAINT fk_posts_user FOREIGN KEY (user_id) REFERENCES public.users(id);
+`,
+			relationship: "(user_id) -> public.users(id)",
+		},
+		{
+			name: "column constraint",
+			sql: `CREATE TABLE public.posts (
+    id bigint NOT NULL,
+    user_id bigint REFERENCES public.users
+);
+`,
+			relationship: "(user_id) -> public.users(id)",
+		},
+		{
+			name: "composite table constraint",
+			sql: `CREATE TABLE public.posts (
+    id bigint NOT NULL,
+    user_id bigint,
+    org_id bigint,
+    FOREIGN KEY (user_id, org_id) REFERENCES public.users(id, org_id)
+);
+`,
+			relationship: "(user_id, org_id) -> public.users(id, org_id)",
+		},
+		{
+			name: "added column",
+			sql: `CREATE TABLE public.posts (
+    id bigint NOT NULL
+);
+
+ALTER TABLE public.posts ADD COLUMN user_id bigint REFERENCES public.users(id);
+`,
+			relationship: "(user_id) -> public.users(id)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(strings.NewReader(tables + "\n" + tt.sql))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			graph := NewGraph(s, false)
+
+			var relationships []string
+			for _, rel := range graph.References("public.posts") {
+				relationships = append(relationships, "("+strings.Join(rel.Columns, ", ")+") -> "+
+					rel.To+"("+strings.Join(rel.RefColumns, ", ")+")")
+			}
+			if want := []string{tt.relationship}; !reflect.DeepEqual(relationships, want) {
+				t.Errorf("References(public.posts) = %q, want %q", relationships, want)
+			}
+
+			if got, want := graph.Closure([]string{"public.posts"}, -1, false), []string{"public.users", "public.orgs"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Closure(public.posts) = %q, want %q", got, want)
+			}
+			if got, want := graph.Closure([]string{"public.orgs"}, 1, true), []string{"public.users"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("inward Closure(public.orgs, 1) = %q, want %q", got, want)
+			}
+		})
+	}
+}
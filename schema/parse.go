@@ -0,0 +1,899 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// Parse reads a schema dump from r and returns the extensions, tables,
+// views, enum, domain, composite and range types, sequences, functions,
+// triggers, foreign keys and indexes it defines. Other constraints and the
+// column defaults added by ALTER TABLE are folded into their tables, and
+// column types are resolved along the search_path the dump sets. Objects
+// created without a schema go in the first schema of that search_path, as
+// they do in Postgres. Every statement, whatever its kind, is also
+// recorded in Schema.Statements with its original text.
+func Parse(r io.Reader) (*Schema, error) {
+	sqlContent, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SQL: %v", err)
+	}
+
+	result, err := pg_query.Parse(string(sqlContent))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SQL: %v", err)
+	}
+
+	src := newSource(string(sqlContent))
+	s := &Schema{}
+	// pg_dump adds constraints after all the tables, but fold them in at
+	// the end in case a dump creates a table after constraining it
+	var constraints []ConstraintDef
+	searchPath := DefaultSearchPath
+	for _, rawStmt := range result.Stmts {
+		if rawStmt.GetStmt() == nil {
+			continue
+		}
+		stmt := src.statement(rawStmt)
+		s.Statements = append(s.Statements, stmt)
+		if path, ok := searchPathOf(stmt.Node); ok {
+			searchPath = path
+		}
+		s.qualifyStatement(stmt.Node, searchPath)
+
+		switch node := stmt.Node.Node.(type) {
+		case *pg_query.Node_CreateStmt:
+			table := ParseCreateTable(node.CreateStmt)
+			table.SQL = stmt.SQL
+			table.Pos = stmt.Pos
+			table.SearchPath = searchPath
+
+			// Foreign keys declared in the table are written out
+			// separately, so the table's SQL has to leave them out
+			foreignKeys := ParseInlineForeignKeys(node.CreateStmt)
+			for i := range foreignKeys {
+				foreignKeys[i].SearchPath = searchPath
+				location := foreignKeys[i].Stmt.GetAlterTableStmt().Cmds[0].GetAlterTableCmd().GetDef().GetConstraint().GetLocation()
+				foreignKeys[i].Pos = src.position(int(location))
+			}
+			s.ForeignKeys = append(s.ForeignKeys, foreignKeys...)
+			if len(foreignKeys) > 0 {
+				sql, err := Deparse(table.Stmt)
+				if err != nil {
+					return nil, fmt.Errorf("%s: error deparsing table %s: %v", stmt.Pos, table.QualifiedName(), err)
+				}
+				table.SQL = sql
+			}
+			for _, element := range node.CreateStmt.TableElts {
+				if def := element.GetColumnDef(); def != nil {
+					if col := table.Column(def.Colname); col != nil {
+						col.Pos = src.position(int(def.Location))
+					}
+				}
+			}
+			s.Tables = append(s.Tables, table)
+		case *pg_query.Node_CreateEnumStmt:
+			enum := ParseCreateEnum(node.CreateEnumStmt)
+			enum.SQL = stmt.SQL
+			enum.Pos = stmt.Pos
+			enum.SearchPath = searchPath
+			s.Enums = append(s.Enums, enum)
+		case *pg_query.Node_CreateDomainStmt:
+			domain, err := ParseCreateDomain(node.CreateDomainStmt)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", stmt.Pos, err)
+			}
+			domain.SQL = stmt.SQL
+			domain.Pos = stmt.Pos
+			domain.SearchPath = searchPath
+			s.Domains = append(s.Domains, domain)
+		case *pg_query.Node_CompositeTypeStmt:
+			typ := ParseCompositeType(node.CompositeTypeStmt)
+			typ.SQL = stmt.SQL
+			typ.Pos = stmt.Pos
+			typ.SearchPath = searchPath
+			for _, element := range node.CompositeTypeStmt.Coldeflist {
+				if def := element.GetColumnDef(); def != nil {
+					for i := range typ.Attributes {
+						if typ.Attributes[i].Name == def.Colname {
+							typ.Attributes[i].Pos = src.position(int(def.Location))
+						}
+					}
+				}
+			}
+			s.CompositeTypes = append(s.CompositeTypes, typ)
+		case *pg_query.Node_CreateRangeStmt:
+			rng := ParseCreateRange(node.CreateRangeStmt)
+			rng.SQL = stmt.SQL
+			rng.Pos = stmt.Pos
+			rng.SearchPath = searchPath
+			s.RangeTypes = append(s.RangeTypes, rng)
+		case *pg_query.Node_ViewStmt:
+			view := ParseCreateView(node.ViewStmt)
+			view.SQL = stmt.SQL
+			view.Pos = stmt.Pos
+			view.SearchPath = searchPath
+			s.findViewReferences(&view)
+			s.Views = append(s.Views, view)
+		case *pg_query.Node_CreateTableAsStmt:
+			if node.CreateTableAsStmt.GetObjtype() != pg_query.ObjectType_OBJECT_MATVIEW {
+				break
+			}
+			view := ParseCreateMaterializedView(node.CreateTableAsStmt)
+			view.SQL = stmt.SQL
+			view.Pos = stmt.Pos
+			view.SearchPath = searchPath
+			s.findViewReferences(&view)
+			s.Views = append(s.Views, view)
+		case *pg_query.Node_CreateFunctionStmt:
+			fn := ParseCreateFunction(node.CreateFunctionStmt)
+			fn.SQL = stmt.SQL
+			fn.Pos = stmt.Pos
+			fn.DumpSearchPath = searchPath
+			s.Functions = append(s.Functions, fn)
+		case *pg_query.Node_CreateTrigStmt:
+			trigger := ParseCreateTrigger(node.CreateTrigStmt)
+			trigger.SQL = stmt.SQL
+			trigger.Pos = stmt.Pos
+			trigger.SearchPath = searchPath
+			s.Triggers = append(s.Triggers, trigger)
+		case *pg_query.Node_CreateSeqStmt:
+			seq := ParseCreateSequence(node.CreateSeqStmt)
+			seq.SQL = stmt.SQL
+			seq.Pos = stmt.Pos
+			seq.SearchPath = searchPath
+			s.Sequences = append(s.Sequences, seq)
+		case *pg_query.Node_AlterSeqStmt:
+			s.ApplyAlterSequence(stmt)
+		case *pg_query.Node_AlterTableStmt:
+			table := s.Table(getTableName(node.AlterTableStmt.Relation))
+			alterations := 0
+			if table != nil {
+				alterations = len(table.ColumnAlterations)
+			}
+			if err := s.ApplyAlterTable(node.AlterTableStmt); err != nil {
+				return nil, fmt.Errorf("%s: %v", stmt.Pos, err)
+			}
+			if table != nil {
+				// Keep the original text of statements that only alter
+				// one column
+				for i := alterations; i < len(table.ColumnAlterations); i++ {
+					if len(node.AlterTableStmt.Cmds) == 1 {
+						table.ColumnAlterations[i].SQL = stmt.SQL
+					}
+					table.ColumnAlterations[i].Pos = stmt.Pos
+				}
+			}
+			foreignKeys := ParseForeignKeys(node.AlterTableStmt)
+			for i := range foreignKeys {
+				// Keep the original text when the statement adds nothing
+				// but the foreign key, so clauses like ON DELETE survive.
+				// One declared on an added column is written on its own.
+				if cmds := node.AlterTableStmt.Cmds; len(cmds) == 1 && cmds[0].GetAlterTableCmd().GetSubtype() == pg_query.AlterTableType_AT_AddConstraint {
+					foreignKeys[i].SQL = stmt.SQL
+				}
+				foreignKeys[i].Pos = stmt.Pos
+				foreignKeys[i].SearchPath = searchPath
+			}
+			s.ForeignKeys = append(s.ForeignKeys, foreignKeys...)
+
+			added := ParseConstraints(node.AlterTableStmt)
+			for i := range added {
+				if len(node.AlterTableStmt.Cmds) == 1 {
+					added[i].SQL = stmt.SQL
+				}
+				added[i].Pos = stmt.Pos
+			}
+			constraints = append(constraints, added...)
+		case *pg_query.Node_IndexStmt:
+			index, err := ParseCreateIndex(node.IndexStmt)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", stmt.Pos, err)
+			}
+			index.SQL = stmt.SQL
+			index.Pos = stmt.Pos
+			index.SearchPath = searchPath
+			s.Indexes = append(s.Indexes, index)
+		case *pg_query.Node_CreateExtensionStmt:
+			ext := ParseCreateExtension(node.CreateExtensionStmt)
+			ext.SQL = stmt.SQL
+			ext.Pos = stmt.Pos
+			s.Extensions = append(s.Extensions, ext)
+		}
+	}
+	s.foldConstraints(constraints)
+	s.resolveReferences()
+	s.resolveTypes()
+	s.findFunctionReferences()
+
+	return s, nil
+}
+
+func getTableName(relation *pg_query.RangeVar) string {
+	if relation == nil {
+		return ""
+	}
+	return QualifiedName(relation.Schemaname, relation.Relname)
+}
+
+// ParseCreateTable converts a CREATE TABLE statement into a TableDef. The
+// SQL field is left empty, and Stmt leaves out the foreign keys, which
+// ParseInlineForeignKeys returns.
+func ParseCreateTable(stmt *pg_query.CreateStmt) TableDef {
+	table := TableDef{
+		Name:   stmt.GetRelation().GetRelname(),
+		Schema: DefaultSchema,
+		Stmt:   &pg_query.Node{Node: &pg_query.Node_CreateStmt{CreateStmt: withoutForeignKeys(stmt)}},
+	}
+	if schema := stmt.GetRelation().GetSchemaname(); schema != "" {
+		table.Schema = schema
+	}
+
+	for _, parent := range stmt.InhRelations {
+		if rangeVar := parent.GetRangeVar(); rangeVar != nil {
+			table.Inherits = append(table.Inherits, getTableName(rangeVar))
+		}
+	}
+
+	for _, element := range stmt.TableElts {
+		switch node := element.Node.(type) {
+		case *pg_query.Node_ColumnDef:
+			table.addColumn(processColumnDef(node.ColumnDef))
+			for _, constraint := range node.ColumnDef.Constraints {
+				table.addCheckFunctions(constraint.GetConstraint())
+			}
+		case *pg_query.Node_Constraint:
+			table.addCheckFunctions(node.Constraint)
+			if node.Constraint.Contype == pg_query.ConstrType_CONSTR_PRIMARY {
+				table.PrimaryKey = stringList(node.Constraint.Keys)
+			}
+			constraint := processConstraint(stmt.GetRelation(), node.Constraint)
+			if constraint != "" {
+				table.Constraints = append(table.Constraints, constraint)
+			}
+		}
+	}
+
+	return table
+}
+
+// addCheckFunctions adds the functions constraint calls to CheckFunctions
+// if it is a CHECK constraint.
+func (t *TableDef) addCheckFunctions(constraint *pg_query.Constraint) {
+	if constraint.GetContype() != pg_query.ConstrType_CONSTR_CHECK {
+		return
+	}
+	functions, _ := expressionDependencies(constraint.GetRawExpr())
+	for _, function := range functions {
+		t.CheckFunctions = appendUnique(t.CheckFunctions, function)
+	}
+}
+
+func processColumnDef(def *pg_query.ColumnDef) ColumnDef {
+	col := ColumnDef{
+		Name:      def.Colname,
+		IsNotNull: def.IsNotNull,
+	}
+
+	if def.TypeName != nil {
+		col.Type, col.BaseType, col.ArrayDims = formatTypeName(def.TypeName)
+	}
+
+	// Get default value
+	if def.RawDefault != nil {
+		col.setDefault(def.RawDefault)
+	}
+
+	// Get column constraints
+	for _, constraint := range def.Constraints {
+		if constraint.Node != nil {
+			switch node := constraint.Node.(type) {
+			case *pg_query.Node_Constraint:
+				switch node.Constraint.Contype {
+				case pg_query.ConstrType_CONSTR_PRIMARY:
+					col.Constraint = "PRIMARY KEY"
+					col.IsNotNull = true
+				case pg_query.ConstrType_CONSTR_NOTNULL:
+					// The raw parse tree keeps NOT NULL as a constraint
+					// rather than setting IsNotNull
+					col.IsNotNull = true
+				case pg_query.ConstrType_CONSTR_DEFAULT:
+					// So does DEFAULT, leaving RawDefault unset
+					col.setDefault(node.Constraint.RawExpr)
+				case pg_query.ConstrType_CONSTR_IDENTITY:
+					col.Identity = identityKinds[node.Constraint.GeneratedWhen]
+					col.Sequence = identitySequence(node.Constraint)
+				}
+			}
+		}
+	}
+
+	return col
+}
+
+// setDefault records expr, or no default if expr is nil, as the column's
+// default, along with the functions and sequences it depends on.
+func (col *ColumnDef) setDefault(expr *pg_query.Node) {
+	col.Default = defaultValue(expr)
+	col.DefaultFunctions, col.DefaultSequences = expressionDependencies(expr)
+	if col.Identity == "" {
+		col.Sequence = nextvalSequence(expr)
+	}
+}
+
+// defaultValue deparses a column default expression into SQL. It returns
+// "" for a nil expression, or one the deparser cannot write.
+func defaultValue(expr *pg_query.Node) string {
+	if expr == nil {
+		return ""
+	}
+	sql, err := DeparseExpr(expr)
+	if err != nil {
+		return ""
+	}
+	return sql
+}
+
+// expressionDependencies returns the functions expr calls, named as
+// written, and the qualified names of the sequences it passes to nextval,
+// currval or setval, each in the order they first appear.
+func expressionDependencies(expr *pg_query.Node) (functions, sequences []string) {
+	walk(expr, func(node *pg_query.Node) bool {
+		call := node.GetFuncCall()
+		if call == nil {
+			return true
+		}
+		functions = appendUnique(functions, strings.Join(stringList(call.GetFuncname()), "."))
+		if seq := sequenceArgument(call); seq != "" {
+			sequences = appendUnique(sequences, seq)
+		}
+		return true
+	})
+	return functions, sequences
+}
+
+// appendUnique appends item to list unless list already holds it.
+func appendUnique(list []string, item string) []string {
+	if contains(list, item) {
+		return list
+	}
+	return append(list, item)
+}
+
+// processConstraint returns the definition of a table constraint declared
+// in the CREATE TABLE of relation, or "" for foreign keys and others it does
+// not handle. Check and exclusion constraints are deparsed the way
+// ParseConstraints deparses those added by ALTER TABLE.
+func processConstraint(relation *pg_query.RangeVar, constraint *pg_query.Constraint) string {
+	switch constraint.Contype {
+	case pg_query.ConstrType_CONSTR_PRIMARY:
+		keys := stringList(constraint.Keys)
+		if len(keys) > 0 {
+			return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", "))
+		}
+	case pg_query.ConstrType_CONSTR_UNIQUE:
+		keys := stringList(constraint.Keys)
+		if len(keys) > 0 {
+			return fmt.Sprintf("UNIQUE (%s)", strings.Join(keys, ", "))
+		}
+	case pg_query.ConstrType_CONSTR_CHECK, pg_query.ConstrType_CONSTR_EXCLUSION:
+		stmt := &pg_query.AlterTableStmt{
+			Relation: relation,
+			Cmds: []*pg_query.Node{{Node: &pg_query.Node_AlterTableCmd{AlterTableCmd: &pg_query.AlterTableCmd{
+				Subtype: pg_query.AlterTableType_AT_AddConstraint,
+				Def:     &pg_query.Node{Node: &pg_query.Node_Constraint{Constraint: constraint}},
+			}}}},
+			Objtype: pg_query.ObjectType_OBJECT_TABLE,
+		}
+		sql, err := Deparse(&pg_query.Node{Node: &pg_query.Node_AlterTableStmt{AlterTableStmt: stmt}})
+		if err == nil {
+			return constraintDefinition(sql)
+		}
+	}
+	return ""
+}
+
+// ParseCreateEnum converts a CREATE TYPE ... AS ENUM statement into an
+// EnumDef. The SQL field is left empty.
+func ParseCreateEnum(stmt *pg_query.CreateEnumStmt) EnumDef {
+	enum := EnumDef{
+		Schema: DefaultSchema,
+		Stmt:   &pg_query.Node{Node: &pg_query.Node_CreateEnumStmt{CreateEnumStmt: stmt}},
+	}
+
+	// Get enum name
+	if len(stmt.TypeName) > 0 {
+		lastNameNode := stmt.TypeName[len(stmt.TypeName)-1]
+		if strNode := lastNameNode.GetString_(); strNode != nil {
+			enum.Name = strNode.GetSval()
+		}
+		if len(stmt.TypeName) > 1 {
+			if strNode := stmt.TypeName[0].GetString_(); strNode != nil {
+				enum.Schema = strNode.GetSval()
+			}
+		}
+	}
+
+	// Get enum values
+	enum.Values = stringList(stmt.Vals)
+
+	return enum
+}
+
+// referentialActions maps the action codes pg_query reports for ON DELETE
+// and ON UPDATE to their SQL keywords.
+var referentialActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+// matchTypes maps the match type codes pg_query reports to their SQL
+// keywords.
+var matchTypes = map[string]string{
+	"s": "SIMPLE",
+	"f": "FULL",
+	"p": "PARTIAL",
+}
+
+// ParseForeignKeys returns the foreign key constraints added by an
+// ALTER TABLE statement, either as a constraint or on a column it adds.
+// Each one's Stmt is an ALTER TABLE that adds only that constraint, and
+// those declared on a column are named the way ParseInlineForeignKeys
+// names them.
+func ParseForeignKeys(stmt *pg_query.AlterTableStmt) []ForeignKeyDef {
+	if stmt == nil {
+		return nil
+	}
+
+	var foreignKeys []ForeignKeyDef
+	for _, cmd := range stmt.Cmds {
+		alterCmd := cmd.GetAlterTableCmd()
+		if def := alterCmd.GetDef().GetColumnDef(); alterCmd.GetSubtype() == pg_query.AlterTableType_AT_AddColumn && def != nil {
+			for _, node := range def.Constraints {
+				if constraint := node.GetConstraint(); constraint.GetContype() == pg_query.ConstrType_CONSTR_FOREIGN {
+					if fk, ok := inlineForeignKey(stmt.Relation, constraint, []string{def.Colname}); ok {
+						foreignKeys = append(foreignKeys, fk)
+					}
+				}
+			}
+			continue
+		}
+		if alterCmd == nil || alterCmd.GetSubtype() != pg_query.AlterTableType_AT_AddConstraint {
+			continue
+		}
+
+		constraint := alterCmd.GetDef().GetConstraint()
+		if constraint == nil || constraint.GetContype() != pg_query.ConstrType_CONSTR_FOREIGN {
+			continue
+		}
+
+		fk, ok := newForeignKey(stmt.Relation, constraint, stringList(constraint.GetFkAttrs()))
+		if !ok {
+			continue
+		}
+		fk.Stmt = singleCommand(stmt, cmd)
+		fk.SQL = foreignKeySQL(fk)
+		foreignKeys = append(foreignKeys, fk)
+	}
+	return foreignKeys
+}
+
+// ParseInlineForeignKeys returns the foreign keys declared inside a
+// CREATE TABLE statement, either on a column or as a table constraint.
+// Each one's Stmt is an ALTER TABLE that adds it, so it can be written out
+// like the foreign keys pg_dump adds, and unnamed ones get the name
+// Postgres would give them. RefColumns is empty when the constraint
+// references the primary key without naming its columns.
+func ParseInlineForeignKeys(stmt *pg_query.CreateStmt) []ForeignKeyDef {
+	if stmt == nil {
+		return nil
+	}
+
+	var foreignKeys []ForeignKeyDef
+	add := func(constraint *pg_query.Constraint, columns []string) {
+		if fk, ok := inlineForeignKey(stmt.Relation, constraint, columns); ok {
+			foreignKeys = append(foreignKeys, fk)
+		}
+	}
+
+	for _, element := range stmt.TableElts {
+		if def := element.GetColumnDef(); def != nil {
+			for _, node := range def.Constraints {
+				if constraint := node.GetConstraint(); constraint.GetContype() == pg_query.ConstrType_CONSTR_FOREIGN {
+					add(constraint, []string{def.Colname})
+				}
+			}
+		} else if constraint := element.GetConstraint(); constraint.GetContype() == pg_query.ConstrType_CONSTR_FOREIGN {
+			add(constraint, stringList(constraint.FkAttrs))
+		}
+	}
+	return foreignKeys
+}
+
+// inlineForeignKey builds the ForeignKeyDef of a foreign key declared
+// within a column or table definition of relation, naming it the way
+// Postgres does if it has no name. Its Stmt is an ALTER TABLE that adds it
+// as a table constraint.
+func inlineForeignKey(relation *pg_query.RangeVar, constraint *pg_query.Constraint, columns []string) (ForeignKeyDef, bool) {
+	fk, ok := newForeignKey(relation, constraint, columns)
+	if !ok {
+		return fk, false
+	}
+	if fk.Name == "" {
+		fk.Name = fmt.Sprintf("%s_%s_fkey", fk.Table, strings.Join(fk.Columns, "_"))
+	}
+
+	var fkAttrs []*pg_query.Node
+	for _, column := range columns {
+		fkAttrs = append(fkAttrs, &pg_query.Node{Node: &pg_query.Node_String_{String_: &pg_query.String{Sval: column}}})
+	}
+	def := &pg_query.Constraint{
+		Contype:        pg_query.ConstrType_CONSTR_FOREIGN,
+		Conname:        fk.Name,
+		Deferrable:     constraint.Deferrable,
+		Initdeferred:   constraint.Initdeferred,
+		Location:       constraint.Location,
+		Pktable:        constraint.Pktable,
+		FkAttrs:        fkAttrs,
+		PkAttrs:        constraint.PkAttrs,
+		FkMatchtype:    constraint.FkMatchtype,
+		FkUpdAction:    constraint.FkUpdAction,
+		FkDelAction:    constraint.FkDelAction,
+		FkDelSetCols:   constraint.FkDelSetCols,
+		SkipValidation: constraint.SkipValidation,
+		InitiallyValid: constraint.InitiallyValid,
+	}
+	fk.Stmt = &pg_query.Node{Node: &pg_query.Node_AlterTableStmt{AlterTableStmt: &pg_query.AlterTableStmt{
+		Relation: relation,
+		Objtype:  pg_query.ObjectType_OBJECT_TABLE,
+		Cmds: []*pg_query.Node{{Node: &pg_query.Node_AlterTableCmd{AlterTableCmd: &pg_query.AlterTableCmd{
+			Subtype: pg_query.AlterTableType_AT_AddConstraint,
+			Def:     &pg_query.Node{Node: &pg_query.Node_Constraint{Constraint: def}},
+		}}}},
+	}}}
+	fk.SQL = foreignKeySQL(fk)
+	return fk, true
+}
+
+// withoutForeignKeys returns a copy of stmt without the foreign keys that
+// ParseInlineForeignKeys moves out of it, or stmt itself if it has none.
+func withoutForeignKeys(stmt *pg_query.CreateStmt) *pg_query.CreateStmt {
+	isForeignKey := func(node *pg_query.Node) bool {
+		return node.GetConstraint().GetContype() == pg_query.ConstrType_CONSTR_FOREIGN
+	}
+
+	changed := false
+	var elements []*pg_query.Node
+	for _, element := range stmt.TableElts {
+		def := element.GetColumnDef()
+		if def == nil {
+			if isForeignKey(element) {
+				changed = true
+			} else {
+				elements = append(elements, element)
+			}
+			continue
+		}
+
+		var constraints []*pg_query.Node
+		for _, constraint := range def.Constraints {
+			if !isForeignKey(constraint) {
+				constraints = append(constraints, constraint)
+			}
+		}
+		if len(constraints) == len(def.Constraints) {
+			elements = append(elements, element)
+			continue
+		}
+		changed = true
+		elements = append(elements, withConstraints(def, constraints))
+	}
+	if !changed {
+		return stmt
+	}
+
+	return withElements(stmt, elements)
+}
+
+// withElements returns a copy of stmt with elements as its columns and
+// table constraints.
+func withElements(stmt *pg_query.CreateStmt, elements []*pg_query.Node) *pg_query.CreateStmt {
+	return &pg_query.CreateStmt{
+		Relation:       stmt.Relation,
+		TableElts:      elements,
+		InhRelations:   stmt.InhRelations,
+		Partbound:      stmt.Partbound,
+		Partspec:       stmt.Partspec,
+		OfTypename:     stmt.OfTypename,
+		Constraints:    stmt.Constraints,
+		Options:        stmt.Options,
+		Oncommit:       stmt.Oncommit,
+		Tablespacename: stmt.Tablespacename,
+		AccessMethod:   stmt.AccessMethod,
+		IfNotExists:    stmt.IfNotExists,
+	}
+}
+
+// withConstraints returns a column definition node that copies def with
+// constraints as its constraints.
+func withConstraints(def *pg_query.ColumnDef, constraints []*pg_query.Node) *pg_query.Node {
+	return &pg_query.Node{Node: &pg_query.Node_ColumnDef{ColumnDef: &pg_query.ColumnDef{
+		Colname:          def.Colname,
+		TypeName:         def.TypeName,
+		Compression:      def.Compression,
+		Inhcount:         def.Inhcount,
+		IsLocal:          def.IsLocal,
+		IsNotNull:        def.IsNotNull,
+		IsFromType:       def.IsFromType,
+		Storage:          def.Storage,
+		RawDefault:       def.RawDefault,
+		CookedDefault:    def.CookedDefault,
+		Identity:         def.Identity,
+		IdentitySequence: def.IdentitySequence,
+		Generated:        def.Generated,
+		CollClause:       def.CollClause,
+		CollOid:          def.CollOid,
+		Constraints:      constraints,
+		Fdwoptions:       def.Fdwoptions,
+		Location:         def.Location,
+	}}}
+}
+
+// newForeignKey builds the ForeignKeyDef of a foreign key constraint on
+// relation over columns. It reports false if the constraint names no
+// columns or referenced table.
+func newForeignKey(relation *pg_query.RangeVar, constraint *pg_query.Constraint, columns []string) (ForeignKeyDef, bool) {
+	pktable := constraint.GetPktable()
+	if len(columns) == 0 || pktable == nil {
+		return ForeignKeyDef{}, false
+	}
+
+	fk := ForeignKeyDef{
+		Name:              constraint.GetConname(),
+		Schema:            DefaultSchema,
+		Table:             relation.GetRelname(),
+		Columns:           columns,
+		RefSchema:         DefaultSchema,
+		RefTable:          pktable.GetRelname(),
+		RefColumns:        stringList(constraint.GetPkAttrs()),
+		OnDelete:          referentialActions[constraint.GetFkDelAction()],
+		OnUpdate:          referentialActions[constraint.GetFkUpdAction()],
+		Match:             matchTypes[constraint.GetFkMatchtype()],
+		Deferrable:        constraint.GetDeferrable(),
+		InitiallyDeferred: constraint.GetInitdeferred(),
+		NotValid:          constraint.GetSkipValidation(),
+	}
+	if fk.OnDelete == "" {
+		fk.OnDelete = "NO ACTION"
+	}
+	if fk.OnUpdate == "" {
+		fk.OnUpdate = "NO ACTION"
+	}
+	if fk.Match == "" {
+		fk.Match = "SIMPLE"
+	}
+	if schema := relation.GetSchemaname(); schema != "" {
+		fk.Schema = schema
+	}
+	if schema := pktable.GetSchemaname(); schema != "" {
+		fk.RefSchema = schema
+	}
+	return fk, true
+}
+
+// foreignKeySQL deparses fk.Stmt, falling back to SQL generated from the
+// fields of fk.
+func foreignKeySQL(fk ForeignKeyDef) string {
+	if sql, err := Deparse(fk.Stmt); err == nil {
+		return sql
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s",
+		QuotedName(fk.Schema, fk.Table),
+		QuoteIdent(fk.Name),
+		strings.Join(fk.Columns, ", "),
+		QuotedName(fk.RefSchema, fk.RefTable))
+	if len(fk.RefColumns) > 0 {
+		sql += fmt.Sprintf(" (%s)", strings.Join(fk.RefColumns, ", "))
+	}
+	if options := fk.Options(); options != "" {
+		sql += " " + options
+	}
+	return sql + ";"
+}
+
+// resolveReferences fills in the RefColumns of foreign keys that reference
+// a table's primary key without naming its columns.
+func (s *Schema) resolveReferences() {
+	for i := range s.ForeignKeys {
+		fk := &s.ForeignKeys[i]
+		if len(fk.RefColumns) > 0 {
+			continue
+		}
+		if table := s.Table(fk.ToTable()); table != nil {
+			fk.RefColumns = table.PrimaryKey
+		}
+	}
+}
+
+// constraintTypes maps the constraint types ParseConstraints handles to
+// their SQL keywords.
+var constraintTypes = map[pg_query.ConstrType]string{
+	pg_query.ConstrType_CONSTR_PRIMARY:   "PRIMARY KEY",
+	pg_query.ConstrType_CONSTR_UNIQUE:    "UNIQUE",
+	pg_query.ConstrType_CONSTR_CHECK:     "CHECK",
+	pg_query.ConstrType_CONSTR_EXCLUSION: "EXCLUDE",
+}
+
+// ParseConstraints returns the primary key, unique, check and exclusion
+// constraints added by an ALTER TABLE statement. Each one's Stmt is an
+// ALTER TABLE that adds only that constraint.
+func ParseConstraints(stmt *pg_query.AlterTableStmt) []ConstraintDef {
+	if stmt == nil {
+		return nil
+	}
+
+	var constraints []ConstraintDef
+	for _, cmd := range stmt.Cmds {
+		alterCmd := cmd.GetAlterTableCmd()
+		if alterCmd == nil || alterCmd.GetSubtype() != pg_query.AlterTableType_AT_AddConstraint {
+			continue
+		}
+		constraint := alterCmd.GetDef().GetConstraint()
+		typ, ok := constraintTypes[constraint.GetContype()]
+		if !ok {
+			continue
+		}
+
+		c := ConstraintDef{
+			Name:    constraint.GetConname(),
+			Schema:  DefaultSchema,
+			Table:   stmt.Relation.GetRelname(),
+			Type:    typ,
+			Columns: stringList(constraint.GetKeys()),
+			Stmt:    singleCommand(stmt, cmd),
+		}
+		if schema := stmt.Relation.GetSchemaname(); schema != "" {
+			c.Schema = schema
+		}
+		if constraint.GetContype() == pg_query.ConstrType_CONSTR_CHECK {
+			c.Functions, _ = expressionDependencies(constraint.GetRawExpr())
+		}
+
+		if sql, err := Deparse(c.Stmt); err == nil {
+			c.SQL = sql
+			c.Definition = constraintDefinition(sql)
+		} else if len(c.Columns) > 0 {
+			c.Definition = fmt.Sprintf("CONSTRAINT %s %s (%s)", QuoteIdent(c.Name), c.Type, strings.Join(c.Columns, ", "))
+			c.SQL = fmt.Sprintf("ALTER TABLE %s ADD %s;", getTableName(stmt.Relation), c.Definition)
+		} else {
+			continue
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints
+}
+
+// constraintDefinition returns the constraint an
+// "ALTER TABLE ... ADD CONSTRAINT ..." statement adds, without the
+// trailing semicolon.
+func constraintDefinition(sql string) string {
+	if i := strings.Index(sql, " ADD "); i >= 0 {
+		sql = sql[i+len(" ADD "):]
+	}
+	return strings.TrimSuffix(strings.TrimSpace(sql), ";")
+}
+
+// singleCommand returns an ALTER TABLE statement with only cmd of stmt, so
+// the object cmd adds can be written out separately from the others.
+func singleCommand(stmt *pg_query.AlterTableStmt, cmd *pg_query.Node) *pg_query.Node {
+	return &pg_query.Node{Node: &pg_query.Node_AlterTableStmt{AlterTableStmt: &pg_query.AlterTableStmt{
+		Relation: stmt.Relation,
+		Cmds:     []*pg_query.Node{cmd},
+		Objtype:  stmt.Objtype,
+	}}}
+}
+
+// foldConstraints adds each constraint to the table it is on. Constraints
+// on tables the schema does not define are dropped.
+func (s *Schema) foldConstraints(constraints []ConstraintDef) {
+	for _, c := range constraints {
+		if table := s.Table(c.TableName()); table != nil {
+			table.AddConstraint(c)
+		}
+	}
+}
+
+// ParseCreateIndex converts a CREATE INDEX statement into an IndexDef.
+// Expressions and the WHERE predicate are deparsed back into SQL. The SQL
+// field is left empty.
+func ParseCreateIndex(stmt *pg_query.IndexStmt) (IndexDef, error) {
+	index := IndexDef{
+		Name:   stmt.GetIdxname(),
+		Schema: DefaultSchema,
+		Table:  stmt.GetRelation().GetRelname(),
+		Unique: stmt.GetUnique(),
+		Method: stmt.GetAccessMethod(),
+		Stmt:   &pg_query.Node{Node: &pg_query.Node_IndexStmt{IndexStmt: stmt}},
+	}
+	if schema := stmt.GetRelation().GetSchemaname(); schema != "" {
+		index.Schema = schema
+	}
+	if index.Method == "" {
+		index.Method = "btree"
+	}
+
+	for _, param := range stmt.GetIndexParams() {
+		elem := param.GetIndexElem()
+		if elem == nil {
+			return IndexDef{}, fmt.Errorf("unexpected key in index %s", index.Name)
+		}
+		col := IndexColumn{
+			Name:       elem.GetName(),
+			Opclass:    strings.Join(stringList(elem.GetOpclass()), "."),
+			Descending: elem.GetOrdering() == pg_query.SortByDir_SORTBY_DESC,
+		}
+		if elem.GetExpr() != nil {
+			expr, err := DeparseExpr(elem.GetExpr())
+			if err != nil {
+				return IndexDef{}, fmt.Errorf("error deparsing key of index %s: %v", index.Name, err)
+			}
+			col.Expression = expr
+		}
+		index.Columns = append(index.Columns, col)
+	}
+	for _, param := range stmt.GetIndexIncludingParams() {
+		if name := param.GetIndexElem().GetName(); name != "" {
+			index.Include = append(index.Include, name)
+		}
+	}
+
+	if stmt.GetWhereClause() != nil {
+		where, err := DeparseExpr(stmt.GetWhereClause())
+		if err != nil {
+			return IndexDef{}, fmt.Errorf("error deparsing predicate of index %s: %v", index.Name, err)
+		}
+		index.Where = where
+	}
+	return index, nil
+}
+
+// ParseCreateExtension converts a CREATE EXTENSION statement into an
+// ExtensionDef, with SQL set to an equivalent CREATE EXTENSION IF NOT
+// EXISTS statement.
+func ParseCreateExtension(stmt *pg_query.CreateExtensionStmt) ExtensionDef {
+	ext := ExtensionDef{
+		Name: stmt.Extname,
+		Stmt: &pg_query.Node{Node: &pg_query.Node_CreateExtensionStmt{CreateExtensionStmt: stmt}},
+	}
+	for _, option := range stmt.Options {
+		if defElem := option.GetDefElem(); defElem != nil && defElem.Defname == "schema" {
+			ext.Schema = defElem.Arg.GetString_().GetSval()
+		}
+	}
+
+	ext.SQL = fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", QuoteIdent(ext.Name))
+	if ext.Schema != "" {
+		ext.SQL += " WITH SCHEMA " + QuoteIdent(ext.Schema)
+	}
+	ext.SQL += ";"
+	return ext
+}
+
+// stringList returns the values of the String nodes in a list, skipping
+// any other node types.
+func stringList(nodes []*pg_query.Node) []string {
+	var values []string
+	for _, node := range nodes {
+		if strNode := node.GetString_(); strNode != nil {
+			values = append(values, strNode.GetSval())
+		}
+	}
+	return values
+}
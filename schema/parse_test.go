@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type column struct {
+		Name      string
+		Type      string
+		BaseType  string
+		ArrayDims int
+		IsNotNull bool
+		UserType  string
+		Sequence  string
+	}
+	type table struct {
+		Name       string
+		PrimaryKey []string
+		Columns    []column
+	}
+	wantTables := []table{
+		{
+			Name:       "public.users",
+			PrimaryKey: []string{"id"},
+			Columns: []column{
+				{Name: "id", Type: "bigint", BaseType: "bigint", IsNotNull: true, Sequence: "public.users_id_seq"},
+				{Name: "email", Type: "character varying(255)", BaseType: "character varying", IsNotNull: true},
+				{Name: "status", Type: "public.status", BaseType: "public.status", UserType: "public.status"},
+				{Name: "created_at", Type: "timestamp(6) without time zone", BaseType: "timestamp without time zone", IsNotNull: true},
+			},
+		},
+		{
+			Name:       "public.posts",
+			PrimaryKey: []string{"id"},
+			Columns: []column{
+				{Name: "id", Type: "bigint", BaseType: "bigint", IsNotNull: true},
+				{Name: "user_id", Type: "bigint", BaseType: "bigint"},
+				{Name: "title", Type: "text", BaseType: "text"},
+				{Name: "tags", Type: "character varying[]", BaseType: "character varying", ArrayDims: 1},
+			},
+		},
+	}
+
+	parsers := []struct {
+		name  string
+		parse func(io.Reader) (*Schema, error)
+	}{
+		{"pg_query", Parse},
+		{"regex", ParseRegex},
+	}
+	for _, parser := range parsers {
+		t.Run(parser.name, func(t *testing.T) {
+			f, err := os.Open("testdata/structure.sql")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			s, err := parser.parse(f)
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+
+			var tables []table
+			for _, parsed := range s.Tables {
+				got := table{Name: parsed.QualifiedName(), PrimaryKey: parsed.PrimaryKey}
+				for _, col := range parsed.Columns {
+					got.Columns = append(got.Columns, column{
+						Name:      col.Name,
+						Type:      col.Type,
+						BaseType:  col.BaseType,
+						ArrayDims: col.ArrayDims,
+						IsNotNull: col.IsNotNull,
+						UserType:  col.UserType,
+						Sequence:  col.Sequence,
+					})
+				}
+				tables = append(tables, got)
+			}
+			if !reflect.DeepEqual(tables, wantTables) {
+				t.Errorf("tables =\n%+v\nwant\n%+v", tables, wantTables)
+			}
+
+			if len(s.Enums) != 1 || s.Enums[0].QualifiedName() != "public.status" ||
+				!reflect.DeepEqual(s.Enums[0].Values, []string{"active", "archived"}) {
+				t.Errorf("enums = %+v, want public.status with active and archived", s.Enums)
+			}
+
+			if len(s.Sequences) != 1 || s.Sequences[0].QualifiedName() != "public.users_id_seq" ||
+				s.Sequences[0].OwnerTable() != "public.users" {
+				t.Errorf("sequences = %+v, want public.users_id_seq owned by public.users", s.Sequences)
+			}
+
+			if len(s.Indexes) != 1 || s.Indexes[0].Name != "index_users_on_email" ||
+				!s.Indexes[0].Unique || s.Indexes[0].TableName() != "public.users" {
+				t.Errorf("indexes = %+v, want unique index_users_on_email on public.users", s.Indexes)
+			}
+
+			if len(s.ForeignKeys) != 1 {
+				t.Fatalf("foreign keys = %+v, want 1", s.ForeignKeys)
+			}
+			fk := s.ForeignKeys[0]
+			if fk.Name != "fk_rails_posts_user" || fk.FromTable() != "public.posts" || fk.ToTable() != "public.users" ||
+				!reflect.DeepEqual(fk.Columns, []string{"user_id"}) || !reflect.DeepEqual(fk.RefColumns, []string{"id"}) ||
+				fk.OnDelete != "CASCADE" {
+				t.Errorf("foreign key = %+v, want fk_rails_posts_user from public.posts(user_id) to public.users(id) ON DELETE CASCADE", fk)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// Position is a location in the parsed dump.
+type Position struct {
+	// Offset is the byte offset from the start of the dump.
+	Offset int
+	// Line is the 1-based line number.
+	Line int
+	// Column is the 1-based column, counted in characters.
+	Column int
+}
+
+// String formats the position as "line:column".
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// IsValid reports whether the position was recorded.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Statement is one statement of the dump.
+type Statement struct {
+	// SQL is the original text of the statement, including its trailing
+	// semicolon but not the comments before it.
+	SQL  string
+	Pos  Position
+	Node *pg_query.Node
+}
+
+// source maps byte offsets in a dump to line and column positions.
+type source struct {
+	text       string
+	lineStarts []int
+}
+
+func newSource(text string) *source {
+	src := &source{text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			src.lineStarts = append(src.lineStarts, i+1)
+		}
+	}
+	return src
+}
+
+// position returns the position of the byte at offset.
+func (src *source) position(offset int) Position {
+	if offset < 0 {
+		return Position{}
+	}
+	if offset > len(src.text) {
+		offset = len(src.text)
+	}
+	line := sort.Search(len(src.lineStarts), func(i int) bool {
+		return src.lineStarts[i] > offset
+	}) - 1
+	return Position{
+		Offset: offset,
+		Line:   line + 1,
+		Column: utf8.RuneCountInString(src.text[src.lineStarts[line]:offset]) + 1,
+	}
+}
+
+// statement slices the text of stmt out of the dump. pg_query reports each
+// statement as starting right after the previous one's semicolon, so the
+// whitespace and comments in between are skipped.
+func (src *source) statement(stmt *pg_query.RawStmt) Statement {
+	start := int(stmt.GetStmtLocation())
+	end := len(src.text)
+	if stmt.GetStmtLen() > 0 {
+		end = start + int(stmt.GetStmtLen())
+	}
+	if start > len(src.text) {
+		start = len(src.text)
+	}
+	if end > len(src.text) {
+		end = len(src.text)
+	}
+
+	start += leadingTrivia(src.text[start:end])
+	text := strings.TrimRightFunc(src.text[start:end], unicode.IsSpace)
+	if end < len(src.text) && src.text[end] == ';' {
+		text += ";"
+	}
+
+	return Statement{
+		SQL:  text,
+		Pos:  src.position(start),
+		Node: stmt.GetStmt(),
+	}
+}
+
+// leadingTrivia returns the length of the whitespace, -- comments and
+// /* */ comments at the start of text.
+func leadingTrivia(text string) int {
+	i := 0
+	for i < len(text) {
+		switch {
+		case text[i] == ' ' || text[i] == '\t' || text[i] == '\n' || text[i] == '\r' || text[i] == '\f':
+			i++
+		case strings.HasPrefix(text[i:], "--"):
+			newline := strings.IndexByte(text[i:], '\n')
+			if newline < 0 {
+				return len(text)
+			}
+			i += newline + 1
+		case strings.HasPrefix(text[i:], "/*"):
+			closing := strings.Index(text[i+2:], "*/")
+			if closing < 0 {
+				return len(text)
+			}
+			i += closing + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
@@ -0,0 +1,744 @@
+package schema
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ParseRegex reads a schema dump from r using line-based regular
+// expressions instead of the Postgres parser. It is faster and tolerant of
+// statements pg_query rejects, but only understands the layout pg_dump
+// produces: CREATE TABLE, CREATE VIEW, CREATE DOMAIN, CREATE TYPE and
+// CREATE SEQUENCE blocks, constraints, column defaults and identities
+// added with ALTER TABLE, OWNED BY and single-line CREATE INDEX
+// statements. Columns and view references are recovered on a best-effort
+// basis, and foreign keys declared inside CREATE TABLE, which pg_dump
+// never writes, are not recognised.
+func ParseRegex(r io.Reader) (*Schema, error) {
+	sqlContent, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SQL: %v", err)
+	}
+
+	tables, err := parseTablesRegex(string(sqlContent))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tables: %v", err)
+	}
+	enums, err := parseEnumsRegex(string(sqlContent))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing enums: %v", err)
+	}
+	foreignKeys, err := parseForeignKeysRegex(string(sqlContent))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing foreign keys: %v", err)
+	}
+
+	s := &Schema{
+		Extensions:  parseExtensionsRegex(string(sqlContent)),
+		Tables:      tables,
+		Enums:       enums,
+		ForeignKeys: foreignKeys,
+	}
+	s.Domains, s.CompositeTypes, s.RangeTypes = parseUserTypesRegex(string(sqlContent))
+	s.foldConstraints(parseConstraintsRegex(string(sqlContent)))
+	s.Indexes = parseIndexesRegex(string(sqlContent))
+	s.Sequences = parseSequencesRegex(string(sqlContent))
+	s.parseViewsRegex(string(sqlContent))
+	s.Functions, s.Triggers = parseFunctionsRegex(string(sqlContent))
+	s.applyColumnAlterationsRegex(string(sqlContent))
+	s.resolveTypes()
+	s.findFunctionReferencesRegex()
+	return s, nil
+}
+
+func parseTablesRegex(sqlContent string) ([]TableDef, error) {
+	var tables []TableDef
+
+	// Pattern to match CREATE TABLE statements
+	tablePattern := regexp.MustCompile(`(?i)CREATE TABLE\s+"?(?:([a-zA-Z0-9_]+)\.)?([a-zA-Z0-9_]+)"?\s*\(`)
+	createTablePattern := regexp.MustCompile(`(?i)^CREATE TABLE`)
+
+	scanner := bufio.NewScanner(strings.NewReader(sqlContent))
+	var currentTable *TableDef
+	depth := 0
+	lineNo := 0
+	searchPath := DefaultSearchPath
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		if currentTable == nil {
+			if path, ok := searchPathRegex(line); ok {
+				searchPath = path
+			}
+			// Look for start of CREATE TABLE
+			if createTablePattern.MatchString(line) {
+				matches := tablePattern.FindStringSubmatch(line)
+				if len(matches) > 2 {
+					schema := creationSchema(searchPath)
+					if matches[1] != "" {
+						schema = matches[1]
+					}
+					currentTable = &TableDef{
+						Schema:     schema,
+						Name:       matches[2],
+						SQL:        line + "\n",
+						Pos:        Position{Line: lineNo, Column: 1},
+						SearchPath: searchPath,
+					}
+					depth = strings.Count(line, "(") - strings.Count(line, ")")
+				}
+			}
+		} else {
+			// Already inside a CREATE TABLE block
+			currentTable.SQL += line + "\n"
+			depth += strings.Count(line, "(") - strings.Count(line, ")")
+
+			// Check if we've reached the end of the CREATE TABLE
+			if depth <= 0 && strings.Contains(line, ");") {
+				tables = append(tables, *currentTable)
+				currentTable = nil
+				depth = 0
+			} else {
+				if col, ok := parseColumnLineRegex(line); ok {
+					col.Pos = Position{Line: lineNo, Column: 1}
+					currentTable.addColumn(col)
+				}
+				if constraint := tableConstraintPattern.FindStringSubmatch(line); constraint != nil {
+					currentTable.Constraints = append(currentTable.Constraints, constraint[1])
+				}
+				if check := checkPattern.FindStringSubmatch(line); check != nil {
+					for _, function := range functionsRegex(check[1]) {
+						currentTable.CheckFunctions = appendUnique(currentTable.CheckFunctions, function)
+					}
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	return tables, nil
+}
+
+var (
+	columnLinePattern    = regexp.MustCompile(`^\s+"?([a-zA-Z0-9_]+)"?\s+(.+?),?$`)
+	columnDefaultPattern = regexp.MustCompile(`(?i)\sDEFAULT\s+(.+?)(?:\s+NOT NULL|\s+NULL|\s+PRIMARY KEY|\s+REFERENCES.*)?$`)
+	checkPattern         = regexp.MustCompile(`\bCHECK (\(.*)$`)
+	columnTypeEnd        = regexp.MustCompile(`(?i)\s+(?:DEFAULT|NOT NULL|NULL|PRIMARY KEY|REFERENCES|COLLATE|CONSTRAINT|GENERATED|UNIQUE|CHECK)\b`)
+
+	// tableConstraintPattern matches a named check or exclusion constraint
+	// on a line of its own in a CREATE TABLE block
+	tableConstraintPattern = regexp.MustCompile(`^\s+(CONSTRAINT "?[a-zA-Z0-9_]+"? (?:CHECK|EXCLUDE)\b.*?),?$`)
+)
+
+// parseColumnLineRegex recognises a single column definition line inside a
+// CREATE TABLE block.
+func parseColumnLineRegex(line string) (ColumnDef, bool) {
+	matches := columnLinePattern.FindStringSubmatch(line)
+	if len(matches) != 3 {
+		return ColumnDef{}, false
+	}
+	switch strings.ToUpper(matches[1]) {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "EXCLUDE":
+		return ColumnDef{}, false
+	}
+
+	definition := matches[2]
+	col := ColumnDef{
+		Name:      matches[1],
+		Type:      definition,
+		IsNotNull: strings.Contains(strings.ToUpper(definition), "NOT NULL"),
+	}
+	if loc := columnTypeEnd.FindStringIndex(definition); loc != nil {
+		col.Type = definition[:loc[0]]
+	}
+	col.BaseType, col.ArrayDims = splitTypeText(col.Type)
+	if identity := identityPattern.FindStringSubmatch(definition); identity != nil {
+		col.Identity = strings.ToUpper(identity[1])
+	}
+	if defaultMatch := columnDefaultPattern.FindStringSubmatch(definition); len(defaultMatch) == 2 {
+		col.setDefaultRegex(defaultMatch[1])
+	}
+	if strings.Contains(strings.ToUpper(definition), "PRIMARY KEY") {
+		col.Constraint = "PRIMARY KEY"
+	}
+	return col, true
+}
+
+var (
+	identityPattern      = regexp.MustCompile(`(?i)\bGENERATED (ALWAYS|BY DEFAULT) AS IDENTITY\b`)
+	nextvalPattern       = regexp.MustCompile(`^nextval\('((?:[^']|'')+)'(?:::regclass)?\)$`)
+	sequenceCallPattern  = regexp.MustCompile(`\b(?:nextval|currval|setval)\('((?:[^']|'')+)'`)
+	stringLiteralPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+	castPattern          = regexp.MustCompile(`::"?[a-zA-Z_][a-zA-Z0-9_."]*(?: varying| precision)?(?:\([^)]*\))?(?: with(?:out)? time zone)?(?:\[\])*`)
+	functionCallPattern  = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_.]*)\s*\(`)
+)
+
+// setDefaultRegex records text as the column's default, along with the
+// functions it calls and the sequences it uses as far as they can be
+// picked out of the text. Type names in casts, which may also be followed
+// by parentheses, are not taken for function calls.
+func (col *ColumnDef) setDefaultRegex(text string) {
+	col.Default = text
+	col.DefaultFunctions, col.DefaultSequences = nil, nil
+	for _, match := range sequenceCallPattern.FindAllStringSubmatch(text, -1) {
+		col.DefaultSequences = appendUnique(col.DefaultSequences, qualifyName(strings.ReplaceAll(match[1], `"`, "")))
+	}
+	col.DefaultFunctions = functionsRegex(text)
+	if col.Identity == "" {
+		col.Sequence = ""
+		if matches := nextvalPattern.FindStringSubmatch(strings.TrimSpace(text)); matches != nil {
+			col.Sequence = qualifyName(strings.ReplaceAll(matches[1], `"`, ""))
+		}
+	}
+}
+
+// keywordsBeforeParens are the keywords that may be followed by an opening
+// parenthesis in an expression, which functionsRegex does not take for
+// function calls.
+var keywordsBeforeParens = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "ANY": true,
+	"ALL": true, "SOME": true, "EXISTS": true, "ARRAY": true, "ROW": true,
+	"WHEN": true, "THEN": true, "ELSE": true, "VALUES": true, "AS": true,
+	"CHECK": true, "SELECT": true, "WHERE": true, "RETURN": true,
+}
+
+// functionsRegex returns the functions SQL text calls, named as written,
+// in the order they first appear.
+func functionsRegex(text string) []string {
+	var functions []string
+	code := castPattern.ReplaceAllString(stringLiteralPattern.ReplaceAllString(text, "''"), "")
+	for _, match := range functionCallPattern.FindAllStringSubmatch(code, -1) {
+		if !keywordsBeforeParens[strings.ToUpper(match[1])] {
+			functions = appendUnique(functions, match[1])
+		}
+	}
+	return functions
+}
+
+var (
+	sequencePattern      = regexp.MustCompile(`^CREATE SEQUENCE (?:IF NOT EXISTS )?"?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"?`)
+	sequenceOwnerPattern = regexp.MustCompile(`^ALTER SEQUENCE "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"? OWNED BY (?:"?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"?|NONE);`)
+	columnDefaultAlter   = regexp.MustCompile(`^ALTER TABLE (?:ONLY )?([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+) ALTER COLUMN "?([a-zA-Z0-9_]+)"? SET DEFAULT (.+);\s*$`)
+	columnIdentityAlter  = regexp.MustCompile(`^ALTER TABLE (?:ONLY )?([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+) ALTER COLUMN "?([a-zA-Z0-9_]+)"? ADD GENERATED (ALWAYS|BY DEFAULT) AS IDENTITY`)
+	identitySequenceName = regexp.MustCompile(`SEQUENCE NAME "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"?`)
+)
+
+// parseSequencesRegex finds the CREATE SEQUENCE blocks pg_dump writes and
+// the ALTER SEQUENCE ... OWNED BY lines that follow them.
+func parseSequencesRegex(sqlContent string) []SequenceDef {
+	var sequences []SequenceDef
+	var current *SequenceDef
+	for i, line := range strings.Split(sqlContent, "\n") {
+		if current == nil {
+			if matches := sequencePattern.FindStringSubmatch(line); matches != nil {
+				current = &SequenceDef{
+					Schema: matches[1],
+					Name:   matches[2],
+					Pos:    Position{Line: i + 1, Column: 1},
+				}
+			}
+		}
+		if current != nil {
+			current.SQL += line + "\n"
+			if strings.HasSuffix(strings.TrimSpace(line), ";") {
+				sequences = append(sequences, *current)
+				current = nil
+			}
+			continue
+		}
+
+		matches := sequenceOwnerPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		for j := range sequences {
+			if sequences[j].Schema != matches[1] || sequences[j].Name != matches[2] {
+				continue
+			}
+			sequences[j].OwnedBy = ""
+			if matches[3] != "" {
+				sequences[j].OwnedBy = strings.Join(matches[3:6], ".")
+			}
+			sequences[j].Ownership = Statement{SQL: line + "\n", Pos: Position{Line: i + 1, Column: 1}}
+		}
+	}
+	return sequences
+}
+
+// applyColumnAlterationsRegex applies the
+// "ALTER TABLE ... ALTER COLUMN ... SET DEFAULT" lines and the
+// "ADD GENERATED ... AS IDENTITY" blocks pg_dump writes for columns that
+// use a sequence to the tables of s.
+func (s *Schema) applyColumnAlterationsRegex(sqlContent string) {
+	var identity *Statement
+	var identityCol *ColumnDef
+	for i, line := range strings.Split(sqlContent, "\n") {
+		pos := Position{Line: i + 1, Column: 1}
+		if identity != nil {
+			identity.SQL += line + "\n"
+			if matches := identitySequenceName.FindStringSubmatch(line); matches != nil && identityCol != nil {
+				identityCol.Sequence = QualifiedName(matches[1], matches[2])
+			}
+			if strings.HasSuffix(strings.TrimSpace(line), ";") {
+				identity = nil
+			}
+			continue
+		}
+
+		if matches := columnDefaultAlter.FindStringSubmatch(line); matches != nil {
+			table := s.Table(QualifiedName(matches[1], matches[2]))
+			if table == nil || table.Column(matches[3]) == nil {
+				continue
+			}
+			table.Column(matches[3]).setDefaultRegex(matches[4])
+			table.ColumnAlterations = append(table.ColumnAlterations, Statement{SQL: line + "\n", Pos: pos})
+			continue
+		}
+
+		matches := columnIdentityAlter.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		table := s.Table(QualifiedName(matches[1], matches[2]))
+		if table == nil || table.Column(matches[3]) == nil {
+			continue
+		}
+		table.ColumnAlterations = append(table.ColumnAlterations, Statement{SQL: line + "\n", Pos: pos})
+		identity = &table.ColumnAlterations[len(table.ColumnAlterations)-1]
+		identityCol = table.Column(matches[3])
+		identityCol.Identity = strings.ToUpper(matches[4])
+		identityCol.Sequence = table.implicitSequence(identityCol.Name)
+		if matches := identitySequenceName.FindStringSubmatch(line); matches != nil {
+			identityCol.Sequence = QualifiedName(matches[1], matches[2])
+		}
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			identity = nil
+		}
+	}
+}
+
+var extensionPattern = regexp.MustCompile(`(?i)^CREATE EXTENSION (?:IF NOT EXISTS )?"?([a-zA-Z0-9_\-]+)"?(?: WITH SCHEMA "?([a-zA-Z0-9_]+)"?)?`)
+
+func parseExtensionsRegex(sqlContent string) []ExtensionDef {
+	var extensions []ExtensionDef
+	for i, line := range strings.Split(sqlContent, "\n") {
+		if matches := extensionPattern.FindStringSubmatch(line); len(matches) == 3 {
+			extensions = append(extensions, ExtensionDef{
+				Name:   matches[1],
+				Schema: matches[2],
+				SQL:    line + "\n",
+				Pos:    Position{Line: i + 1, Column: 1},
+			})
+		}
+	}
+	return extensions
+}
+
+func parseEnumsRegex(sqlContent string) ([]EnumDef, error) {
+	var enums []EnumDef
+	scanner := bufio.NewScanner(strings.NewReader(sqlContent))
+	var currentEnum *EnumDef
+	valuePattern := regexp.MustCompile(`'((?:[^']|'')*)'`)
+	lineNo := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		if currentEnum == nil {
+			if strings.Contains(line, "CREATE TYPE") && strings.Contains(line, "AS ENUM") {
+				// Extract schema and name from CREATE TYPE public.some_enum_type AS ENUM
+				parts := strings.Split(strings.Split(line, "AS ENUM")[0], ".")
+				if len(parts) == 2 {
+					schema := strings.TrimSpace(strings.Split(parts[0], "TYPE")[1])
+					name := strings.TrimSpace(parts[1])
+					currentEnum = &EnumDef{
+						Schema: schema,
+						Name:   name,
+						SQL:    line + "\n",
+						Pos:    Position{Line: lineNo, Column: 1},
+					}
+				}
+			}
+		} else {
+			currentEnum.SQL += line + "\n"
+			for _, match := range valuePattern.FindAllStringSubmatch(line, -1) {
+				currentEnum.Values = append(currentEnum.Values, strings.ReplaceAll(match[1], "''", "'"))
+			}
+			if strings.Contains(line, ");") {
+				enums = append(enums, *currentEnum)
+				currentEnum = nil
+			}
+		}
+	}
+
+	return enums, scanner.Err()
+}
+
+var (
+	domainPattern        = regexp.MustCompile(`^CREATE DOMAIN "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"? AS (.+)$`)
+	domainCheckPattern   = regexp.MustCompile(`^\s+((?:CONSTRAINT "?[a-zA-Z0-9_]+"? )?CHECK .+?);?$`)
+	compositeTypePattern = regexp.MustCompile(`^CREATE TYPE "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"? AS \($`)
+	rangeTypePattern     = regexp.MustCompile(`^CREATE TYPE "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"? AS RANGE \($`)
+	rangeSubtypePattern  = regexp.MustCompile(`^\s+subtype = (.+?),?$`)
+)
+
+// addFunctionsRegex adds the functions called in text, a default or CHECK
+// constraint, to Functions.
+func (d *DomainDef) addFunctionsRegex(text string) {
+	for _, function := range functionsRegex(text) {
+		d.Functions = appendUnique(d.Functions, function)
+	}
+}
+
+// parseUserTypesRegex finds the CREATE DOMAIN blocks, with their CHECK
+// constraints on the lines that follow, and the composite and range
+// CREATE TYPE blocks pg_dump writes.
+func parseUserTypesRegex(sqlContent string) (domains []DomainDef, composites []CompositeTypeDef, ranges []RangeTypeDef) {
+	var domain *DomainDef
+	var composite *CompositeTypeDef
+	var rng *RangeTypeDef
+	searchPath := DefaultSearchPath
+	for i, line := range strings.Split(sqlContent, "\n") {
+		pos := Position{Line: i + 1, Column: 1}
+		end := strings.HasSuffix(strings.TrimSpace(line), ";")
+		switch {
+		case domain != nil:
+			domain.SQL += line + "\n"
+			if matches := domainCheckPattern.FindStringSubmatch(line); matches != nil {
+				domain.Checks = append(domain.Checks, matches[1])
+				domain.addFunctionsRegex(matches[1])
+			}
+			if end {
+				domains = append(domains, *domain)
+				domain = nil
+			}
+			continue
+		case composite != nil:
+			composite.SQL += line + "\n"
+			if end {
+				composites = append(composites, *composite)
+				composite = nil
+			} else if attr, ok := parseColumnLineRegex(line); ok {
+				attr.Pos = pos
+				composite.Attributes = append(composite.Attributes, attr)
+			}
+			continue
+		case rng != nil:
+			rng.SQL += line + "\n"
+			if matches := rangeSubtypePattern.FindStringSubmatch(line); matches != nil {
+				rng.Subtype = matches[1]
+			}
+			if end {
+				ranges = append(ranges, *rng)
+				rng = nil
+			}
+			continue
+		}
+
+		if path, ok := searchPathRegex(line); ok {
+			searchPath = path
+		}
+		if matches := domainPattern.FindStringSubmatch(line); matches != nil {
+			domain = &DomainDef{Schema: matches[1], Name: matches[2], SearchPath: searchPath, SQL: line + "\n", Pos: pos}
+			// pg_dump writes the type, then COLLATE, DEFAULT and NOT NULL
+			typ := strings.TrimSuffix(strings.TrimSpace(matches[3]), ";")
+			if strings.HasSuffix(typ, " NOT NULL") {
+				domain.NotNull = true
+				typ = strings.TrimSuffix(typ, " NOT NULL")
+			}
+			if j := strings.Index(typ, " DEFAULT "); j >= 0 {
+				domain.Default = typ[j+len(" DEFAULT "):]
+				domain.addFunctionsRegex(domain.Default)
+				typ = typ[:j]
+			}
+			if j := strings.Index(typ, " COLLATE "); j >= 0 {
+				typ = typ[:j]
+			}
+			domain.Type = typ
+			domain.BaseType, _ = splitTypeText(typ)
+			if end {
+				domains = append(domains, *domain)
+				domain = nil
+			}
+		} else if matches := compositeTypePattern.FindStringSubmatch(line); matches != nil {
+			composite = &CompositeTypeDef{Schema: matches[1], Name: matches[2], SearchPath: searchPath, SQL: line + "\n", Pos: pos}
+		} else if matches := rangeTypePattern.FindStringSubmatch(line); matches != nil {
+			rng = &RangeTypeDef{Schema: matches[1], Name: matches[2], SearchPath: searchPath, SQL: line + "\n", Pos: pos}
+		}
+	}
+	return domains, composites, ranges
+}
+
+func parseForeignKeysRegex(sqlContent string) ([]ForeignKeyDef, error) {
+	var foreignKeys []ForeignKeyDef
+	scanner := bufio.NewScanner(strings.NewReader(sqlContent))
+
+	// Pattern to match ALTER TABLE ... DROP CONSTRAINT ... fk_rails_...
+	dropPattern := regexp.MustCompile(`ALTER TABLE IF EXISTS ONLY ([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+) DROP CONSTRAINT IF EXISTS (fk_rails_[a-zA-Z0-9_]+);`)
+
+	// Map to store constraint names and their corresponding tables
+	constraintMap := make(map[string]string)
+
+	// First pass: collect all constraint names and their tables
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := dropPattern.FindStringSubmatch(line); len(matches) == 4 {
+			tableName := fmt.Sprintf("%s.%s", matches[1], matches[2])
+			constraintName := matches[3]
+			constraintMap[constraintName] = tableName
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Second pass over the content
+	scanner = bufio.NewScanner(strings.NewReader(sqlContent))
+
+	// Pattern to match the columns and REFERENCES in ALTER TABLE statements
+	refPattern := regexp.MustCompile(`FOREIGN KEY \(([^)]*)\) REFERENCES ([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+)\s*\(([^)]*)\)`)
+
+	// Second pass: find the actual foreign key definitions
+	lastAlteredTable := ""
+	lastAlterLine := 0
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		if matches := alterTablePattern.FindStringSubmatch(line); len(matches) == 3 {
+			lastAlteredTable = fmt.Sprintf("%s.%s", matches[1], matches[2])
+			lastAlterLine = lineNo
+		}
+		if strings.Contains(line, "ADD CONSTRAINT") && strings.Contains(line, "FOREIGN KEY") {
+			// Extract constraint name
+			parts := strings.Split(line, "ADD CONSTRAINT")
+			if len(parts) != 2 {
+				continue
+			}
+			constraintPart := strings.TrimSpace(parts[1])
+			constraintName := strings.Split(constraintPart, " ")[0]
+
+			// Get the source table from our constraint map, falling back
+			// to the table named by the preceding ALTER TABLE line
+			fromTable, ok := constraintMap[constraintName]
+			if !ok {
+				fromTable = lastAlteredTable
+			}
+			fromParts := strings.Split(fromTable, ".")
+			if len(fromParts) != 2 {
+				continue
+			}
+
+			// Extract the referenced table
+			refMatches := refPattern.FindStringSubmatch(line)
+			if len(refMatches) == 5 {
+				fk := ForeignKeyDef{
+					Name:       constraintName,
+					Schema:     fromParts[0],
+					Table:      fromParts[1],
+					Columns:    splitColumnList(refMatches[1]),
+					RefSchema:  refMatches[2],
+					RefTable:   refMatches[3],
+					RefColumns: splitColumnList(refMatches[4]),
+				}
+				parseForeignKeyOptionsRegex(&fk, line)
+				fk.SQL = fmt.Sprintf("ALTER TABLE ONLY %s\n    %s", fk.FromTable(), strings.TrimSpace(line))
+				fk.Pos = Position{Line: lastAlterLine, Column: 1}
+				foreignKeys = append(foreignKeys, fk)
+			}
+		}
+	}
+
+	return foreignKeys, scanner.Err()
+}
+
+var (
+	// pg_dump writes "ALTER TABLE ONLY schema.table" on its own line,
+	// followed by the ADD CONSTRAINT line
+	alterTablePattern    = regexp.MustCompile(`ALTER TABLE (?:ONLY )?([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+)`)
+	addConstraintPattern = regexp.MustCompile(`ADD (CONSTRAINT "?([a-zA-Z0-9_]+)"? (PRIMARY KEY|UNIQUE|CHECK|EXCLUDE)\b.*?);?\s*$`)
+	keyColumnsPattern    = regexp.MustCompile(`\(([^)]*)\)`)
+)
+
+// parseConstraintsRegex finds the primary key, unique, check and exclusion
+// constraints pg_dump adds with "ALTER TABLE ONLY schema.table" followed by
+// an ADD CONSTRAINT line.
+func parseConstraintsRegex(sqlContent string) []ConstraintDef {
+	var constraints []ConstraintDef
+	lastAlteredTable := []string(nil)
+	lastAlterLine := 0
+	for i, line := range strings.Split(sqlContent, "\n") {
+		if matches := alterTablePattern.FindStringSubmatch(line); len(matches) == 3 {
+			lastAlteredTable = matches[1:]
+			lastAlterLine = i + 1
+		}
+		matches := addConstraintPattern.FindStringSubmatch(line)
+		if len(matches) != 4 || lastAlteredTable == nil {
+			continue
+		}
+
+		c := ConstraintDef{
+			Name:       matches[2],
+			Schema:     lastAlteredTable[0],
+			Table:      lastAlteredTable[1],
+			Type:       matches[3],
+			Definition: matches[1],
+			Pos:        Position{Line: lastAlterLine, Column: 1},
+		}
+		if c.Type == "PRIMARY KEY" || c.Type == "UNIQUE" {
+			if keys := keyColumnsPattern.FindStringSubmatch(matches[1]); len(keys) == 2 {
+				c.Columns = splitColumnList(keys[1])
+			}
+		}
+		if check := checkPattern.FindStringSubmatch(matches[1]); c.Type == "CHECK" && check != nil {
+			c.Functions = functionsRegex(check[1])
+		}
+		c.SQL = fmt.Sprintf("ALTER TABLE ONLY %s\n    %s", c.TableName(), strings.TrimSpace(line))
+		constraints = append(constraints, c)
+	}
+	return constraints
+}
+
+var (
+	onDeletePattern = regexp.MustCompile(`(?i)\bON DELETE (NO ACTION|RESTRICT|CASCADE|SET NULL|SET DEFAULT)\b`)
+	onUpdatePattern = regexp.MustCompile(`(?i)\bON UPDATE (NO ACTION|RESTRICT|CASCADE|SET NULL|SET DEFAULT)\b`)
+	matchPattern    = regexp.MustCompile(`(?i)\bMATCH (SIMPLE|FULL|PARTIAL)\b`)
+	notDeferrable   = regexp.MustCompile(`(?i)\bNOT DEFERRABLE\b`)
+)
+
+// parseForeignKeyOptionsRegex reads the clauses after REFERENCES in an
+// ADD CONSTRAINT ... FOREIGN KEY line into fk.
+func parseForeignKeyOptionsRegex(fk *ForeignKeyDef, line string) {
+	fk.OnDelete, fk.OnUpdate, fk.Match = "NO ACTION", "NO ACTION", "SIMPLE"
+	if matches := onDeletePattern.FindStringSubmatch(line); matches != nil {
+		fk.OnDelete = strings.ToUpper(matches[1])
+	}
+	if matches := onUpdatePattern.FindStringSubmatch(line); matches != nil {
+		fk.OnUpdate = strings.ToUpper(matches[1])
+	}
+	if matches := matchPattern.FindStringSubmatch(line); matches != nil {
+		fk.Match = strings.ToUpper(matches[1])
+	}
+	upper := strings.ToUpper(line)
+	fk.InitiallyDeferred = strings.Contains(upper, "INITIALLY DEFERRED")
+	fk.Deferrable = fk.InitiallyDeferred || (strings.Contains(upper, "DEFERRABLE") && !notDeferrable.MatchString(line))
+	fk.NotValid = strings.Contains(upper, "NOT VALID")
+}
+
+var (
+	indexPattern    = regexp.MustCompile(`^CREATE (UNIQUE )?INDEX (?:CONCURRENTLY )?(?:IF NOT EXISTS )?"?([a-zA-Z0-9_]+)"? ON (?:ONLY )?([a-zA-Z0-9_]+)\.([a-zA-Z0-9_]+) USING ([a-z]+) \((.*);\s*$`)
+	indexKeyPattern = regexp.MustCompile(`(?i)^"?([a-zA-Z0-9_]+)"?(?:\s+([a-zA-Z0-9_.]+))?(?:\s+(ASC|DESC))?(?:\s+NULLS (?:FIRST|LAST))?$`)
+	descPattern     = regexp.MustCompile(`(?i)\s+DESC(?:\s+NULLS (?:FIRST|LAST))?$`)
+)
+
+// parseIndexesRegex finds the CREATE INDEX statements pg_dump writes, one
+// per line, such as
+// "CREATE UNIQUE INDEX name ON public.t USING btree (a, lower(b)) WHERE c;".
+func parseIndexesRegex(sqlContent string) []IndexDef {
+	var indexes []IndexDef
+	for i, line := range strings.Split(sqlContent, "\n") {
+		matches := indexPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		keys, rest, ok := splitParens(matches[6])
+		if !ok {
+			continue
+		}
+
+		index := IndexDef{
+			Name:   matches[2],
+			Schema: matches[3],
+			Table:  matches[4],
+			Unique: matches[1] != "",
+			Method: matches[5],
+			SQL:    line + "\n",
+			Pos:    Position{Line: i + 1, Column: 1},
+		}
+		for _, key := range splitTopLevel(keys) {
+			col := IndexColumn{Descending: descPattern.MatchString(key)}
+			if m := indexKeyPattern.FindStringSubmatch(key); m != nil && !strings.EqualFold(m[2], "ASC") && !strings.EqualFold(m[2], "DESC") {
+				col.Name, col.Opclass = m[1], m[2]
+			} else {
+				col.Expression = descPattern.ReplaceAllString(key, "")
+			}
+			index.Columns = append(index.Columns, col)
+		}
+
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "INCLUDE (") {
+			include, after, ok := splitParens(strings.TrimPrefix(rest, "INCLUDE ("))
+			if ok {
+				index.Include = splitColumnList(include)
+				rest = strings.TrimSpace(after)
+			}
+		}
+		if strings.HasPrefix(rest, "WHERE ") {
+			index.Where = strings.TrimPrefix(rest, "WHERE ")
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// splitParens splits text that follows an opening parenthesis at the
+// matching closing one, returning what is inside and what follows.
+func splitParens(text string) (inside, rest string, ok bool) {
+	depth := 1
+	quoted := false
+	for i, r := range text {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth == 0 {
+				return text[:i], text[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// splitTopLevel splits a comma-separated list, ignoring commas inside
+// parentheses and string literals.
+func splitTopLevel(list string) []string {
+	var items []string
+	depth, start := 0, 0
+	quoted := false
+	for i, r := range list {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, strings.TrimSpace(list[start:]))
+}
+
+func splitColumnList(list string) []string {
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		columns = append(columns, strings.Trim(strings.TrimSpace(column), `"`))
+	}
+	return columns
+}
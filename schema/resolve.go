@@ -0,0 +1,310 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// DefaultSearchPath is the search_path a dump starts with. The server's
+// default also lists the "$user" schema, which dumps do not rely on.
+var DefaultSearchPath = []string{DefaultSchema}
+
+// TypeReference is a use of a type that is neither built in, nor defined
+// by the dump, nor provided by an extension the dump installs.
+type TypeReference struct {
+	// Object is the qualified name of the table or composite type whose
+	// column uses the type, or of the domain or range type built on it.
+	Object string
+	// Column is the column or attribute that uses the type, or "" for a
+	// domain or range type.
+	Column string
+	// Type is the base type as written.
+	Type string
+	Pos  Position
+}
+
+// builtinTypes lists the base types pg_catalog provides, by the names
+// CanonicalTypeName returns. pg_catalog is searched before any schema on
+// the search_path, so these names never refer to a user-defined type.
+var builtinTypes = map[string]bool{
+	"smallint": true, "integer": true, "bigint": true, "numeric": true,
+	"real": true, "double precision": true, "money": true,
+	"smallserial": true, "serial": true, "bigserial": true,
+	"serial2": true, "serial4": true, "serial8": true,
+	"boolean": true, "text": true, "character varying": true,
+	"character": true, "char": true, "name": true,
+	"bytea": true, "bit": true, "bit varying": true, "uuid": true,
+	"date": true, "interval": true,
+	"time without time zone": true, "time with time zone": true,
+	"timestamp without time zone": true, "timestamp with time zone": true,
+	"json": true, "jsonb": true, "jsonpath": true, "xml": true,
+	"inet": true, "cidr": true, "macaddr": true, "macaddr8": true,
+	"point": true, "line": true, "lseg": true, "box": true, "path": true,
+	"polygon": true, "circle": true, "tsvector": true, "tsquery": true,
+	"int4range": true, "int8range": true, "numrange": true,
+	"tsrange": true, "tstzrange": true, "daterange": true,
+	"int4multirange": true, "int8multirange": true, "nummultirange": true,
+	"tsmultirange": true, "tstzmultirange": true, "datemultirange": true,
+	"oid": true, "regclass": true, "regtype": true, "regproc": true,
+	"regprocedure": true, "regconfig": true, "regnamespace": true,
+	"regrole": true, "pg_lsn": true, "pg_snapshot": true,
+	"txid_snapshot": true, "record": true,
+}
+
+// extensionTypes lists the types of commonly installed extensions, which
+// the dump uses without defining them.
+var extensionTypes = map[string][]string{
+	"citext":  {"citext"},
+	"hstore":  {"hstore"},
+	"ltree":   {"ltree", "lquery", "ltxtquery"},
+	"cube":    {"cube"},
+	"isn":     {"ean13", "isbn", "isbn13", "ismn", "ismn13", "issn", "issn13", "upc"},
+	"postgis": {"geometry", "geography", "box2d", "box3d"},
+	"vector":  {"vector", "halfvec", "sparsevec"},
+	"seg":     {"seg"},
+}
+
+// ResolveType returns the qualified name of the user-defined type that
+// name, a base type as written in a column definition, refers to when
+// looked up along searchPath, or "" if it names no type the dump defines.
+func (s *Schema) ResolveType(name string, searchPath []string) string {
+	name = strings.ReplaceAll(name, `"`, "")
+	if builtinTypes[name] {
+		return ""
+	}
+	if strings.Contains(name, ".") {
+		if s.hasType(name) {
+			return name
+		}
+		return ""
+	}
+	for _, schema := range searchPath {
+		if qualified := QualifiedName(schema, name); s.hasType(qualified) {
+			return qualified
+		}
+	}
+	return ""
+}
+
+// hasType reports whether the dump defines a type with the given
+// qualified name.
+func (s *Schema) hasType(name string) bool {
+	return s.Enum(name) != nil || s.Domain(name) != nil || s.CompositeType(name) != nil || s.RangeType(name) != nil
+}
+
+// isExtensionType reports whether name, qualified or not, is a type of an
+// extension s installs.
+func (s *Schema) isExtensionType(name string) bool {
+	name = name[strings.LastIndex(name, ".")+1:]
+	for _, ext := range s.Extensions {
+		if contains(extensionTypes[ext.Name], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveTypes sets the UserType of every column, composite type
+// attribute, domain and range type to the type its base type resolves to
+// along the search path it was created with, and lists the types that
+// cannot be resolved in UnresolvedTypes.
+func (s *Schema) resolveTypes() {
+	s.UnresolvedTypes = nil
+	for i := range s.Tables {
+		table := &s.Tables[i]
+		for j := range table.Columns {
+			col := &table.Columns[j]
+			col.UserType = s.resolve(TypeReference{table.QualifiedName(), col.Name, col.BaseType, col.Pos}, table.SearchPath)
+		}
+	}
+	for i := range s.CompositeTypes {
+		typ := &s.CompositeTypes[i]
+		for j := range typ.Attributes {
+			attr := &typ.Attributes[j]
+			attr.UserType = s.resolve(TypeReference{typ.QualifiedName(), attr.Name, attr.BaseType, attr.Pos}, typ.SearchPath)
+		}
+	}
+	for i := range s.Domains {
+		domain := &s.Domains[i]
+		domain.UserType = s.resolve(TypeReference{domain.QualifiedName(), "", domain.BaseType, domain.Pos}, domain.SearchPath)
+	}
+	for i := range s.RangeTypes {
+		rng := &s.RangeTypes[i]
+		base, _ := splitTypeText(rng.Subtype)
+		rng.UserType = s.resolve(TypeReference{rng.QualifiedName(), "", base, rng.Pos}, rng.SearchPath)
+	}
+}
+
+// resolve returns the user-defined type ref.Type resolves to along
+// searchPath, adding ref to UnresolvedTypes if it names no known type.
+func (s *Schema) resolve(ref TypeReference, searchPath []string) string {
+	userType := s.ResolveType(ref.Type, searchPath)
+	name := strings.ReplaceAll(ref.Type, `"`, "")
+	if userType == "" && name != "" && !builtinTypes[name] && !s.isExtensionType(name) {
+		s.UnresolvedTypes = append(s.UnresolvedTypes, ref)
+	}
+	return userType
+}
+
+// parseSearchPath splits a search_path setting such as
+// `billing, "$user", public` into its schemas, leaving out "$user".
+func parseSearchPath(value string) []string {
+	path := []string{}
+	for _, schema := range strings.Split(value, ",") {
+		schema = strings.Trim(strings.TrimSpace(schema), `"`)
+		if schema != "" && schema != "$user" {
+			path = append(path, schema)
+		}
+	}
+	return path
+}
+
+// searchPathOf returns the search path set by a SET search_path statement
+// or by the set_config('search_path', ...) call pg_dump writes, and false
+// for any other statement.
+func searchPathOf(stmt *pg_query.Node) ([]string, bool) {
+	if set := stmt.GetVariableSetStmt(); set != nil {
+		if set.GetName() != "search_path" {
+			return nil, false
+		}
+		switch set.GetKind() {
+		case pg_query.VariableSetKind_VAR_SET_VALUE:
+			var values []string
+			for _, arg := range set.GetArgs() {
+				values = append(values, arg.GetAConst().GetSval().GetSval())
+			}
+			return parseSearchPath(strings.Join(values, ",")), true
+		case pg_query.VariableSetKind_VAR_SET_DEFAULT, pg_query.VariableSetKind_VAR_RESET, pg_query.VariableSetKind_VAR_RESET_ALL:
+			return DefaultSearchPath, true
+		}
+		return nil, false
+	}
+
+	targets := stmt.GetSelectStmt().GetTargetList()
+	if len(targets) != 1 {
+		return nil, false
+	}
+	call := targets[0].GetResTarget().GetVal().GetFuncCall()
+	names := stringList(call.GetFuncname())
+	if len(names) == 0 || names[len(names)-1] != "set_config" || len(call.GetArgs()) < 2 {
+		return nil, false
+	}
+	if call.GetArgs()[0].GetAConst().GetSval().GetSval() != "search_path" {
+		return nil, false
+	}
+	return parseSearchPath(call.GetArgs()[1].GetAConst().GetSval().GetSval()), true
+}
+
+var (
+	setSearchPathPattern = regexp.MustCompile(`(?i)^SET\s+(?:SESSION\s+|LOCAL\s+)?search_path\s*(?:=|TO)\s*(.*?);`)
+	setConfigPattern     = regexp.MustCompile(`(?i)\bset_config\('search_path',\s*'((?:[^']|'')*)'`)
+)
+
+// searchPathRegex is searchPathOf for a line of a dump read by the regex
+// backend.
+func searchPathRegex(line string) ([]string, bool) {
+	if matches := setSearchPathPattern.FindStringSubmatch(line); matches != nil {
+		value := strings.TrimSpace(matches[1])
+		if strings.EqualFold(value, "DEFAULT") {
+			return DefaultSearchPath, true
+		}
+		return parseSearchPath(strings.ReplaceAll(value, "'", "")), true
+	}
+	if matches := setConfigPattern.FindStringSubmatch(line); matches != nil {
+		return parseSearchPath(matches[1]), true
+	}
+	return nil, false
+}
+
+// creationSchema returns the schema Postgres creates an object whose name is
+// not schema-qualified in: the first schema of searchPath.
+func creationSchema(searchPath []string) string {
+	if len(searchPath) == 0 {
+		return DefaultSchema
+	}
+	return searchPath[0]
+}
+
+// qualifyStatement fills in the schema of the names stmt leaves
+// unqualified, the way Postgres resolves them along searchPath: objects it
+// creates go in the first schema of searchPath, and the tables it alters,
+// indexes, triggers on or references are looked up in each schema in
+// turn. Tables the schema does not define yet are left unqualified.
+func (s *Schema) qualifyStatement(stmt *pg_query.Node, searchPath []string) {
+	schema := creationSchema(searchPath)
+	switch node := stmt.Node.(type) {
+	case *pg_query.Node_CreateStmt:
+		qualifyRangeVar(node.CreateStmt.GetRelation(), schema)
+		for _, element := range node.CreateStmt.GetTableElts() {
+			s.qualifyConstraint(element.GetConstraint(), searchPath)
+			for _, constraint := range element.GetColumnDef().GetConstraints() {
+				s.qualifyConstraint(constraint.GetConstraint(), searchPath)
+			}
+		}
+	case *pg_query.Node_CreateEnumStmt:
+		node.CreateEnumStmt.TypeName = qualifyNameList(node.CreateEnumStmt.GetTypeName(), schema)
+	case *pg_query.Node_CreateDomainStmt:
+		node.CreateDomainStmt.Domainname = qualifyNameList(node.CreateDomainStmt.GetDomainname(), schema)
+	case *pg_query.Node_CompositeTypeStmt:
+		qualifyRangeVar(node.CompositeTypeStmt.GetTypevar(), schema)
+	case *pg_query.Node_CreateRangeStmt:
+		node.CreateRangeStmt.TypeName = qualifyNameList(node.CreateRangeStmt.GetTypeName(), schema)
+	case *pg_query.Node_ViewStmt:
+		qualifyRangeVar(node.ViewStmt.GetView(), schema)
+	case *pg_query.Node_CreateTableAsStmt:
+		qualifyRangeVar(node.CreateTableAsStmt.GetInto().GetRel(), schema)
+	case *pg_query.Node_CreateFunctionStmt:
+		node.CreateFunctionStmt.Funcname = qualifyNameList(node.CreateFunctionStmt.GetFuncname(), schema)
+	case *pg_query.Node_CreateSeqStmt:
+		qualifyRangeVar(node.CreateSeqStmt.GetSequence(), schema)
+	case *pg_query.Node_AlterTableStmt:
+		s.resolveRangeVar(node.AlterTableStmt.GetRelation(), searchPath)
+		for _, cmd := range node.AlterTableStmt.GetCmds() {
+			s.qualifyConstraint(cmd.GetAlterTableCmd().GetDef().GetConstraint(), searchPath)
+		}
+	case *pg_query.Node_IndexStmt:
+		s.resolveRangeVar(node.IndexStmt.GetRelation(), searchPath)
+	case *pg_query.Node_CreateTrigStmt:
+		s.resolveRangeVar(node.CreateTrigStmt.GetRelation(), searchPath)
+	}
+}
+
+// qualifyConstraint resolves the table a foreign key constraint
+// references along searchPath.
+func (s *Schema) qualifyConstraint(constraint *pg_query.Constraint, searchPath []string) {
+	if constraint.GetContype() == pg_query.ConstrType_CONSTR_FOREIGN {
+		s.resolveRangeVar(constraint.GetPktable(), searchPath)
+	}
+}
+
+// resolveRangeVar sets the schema of an unqualified relation to the first
+// schema of searchPath that has a table or view of that name.
+func (s *Schema) resolveRangeVar(rel *pg_query.RangeVar, searchPath []string) {
+	if rel == nil || rel.Schemaname != "" {
+		return
+	}
+	for _, schema := range searchPath {
+		name := QualifiedName(schema, rel.Relname)
+		if s.Table(name) != nil || s.View(name) != nil {
+			rel.Schemaname = schema
+			return
+		}
+	}
+}
+
+// qualifyRangeVar sets the schema of rel to schema if it has none.
+func qualifyRangeVar(rel *pg_query.RangeVar, schema string) {
+	if rel != nil && rel.Schemaname == "" {
+		rel.Schemaname = schema
+	}
+}
+
+// qualifyNameList prepends schema to a name list that has only a name.
+func qualifyNameList(names []*pg_query.Node, schema string) []*pg_query.Node {
+	if len(names) != 1 {
+		return names
+	}
+	return []*pg_query.Node{{Node: &pg_query.Node_String_{String_: &pg_query.String{Sval: schema}}}, names[0]}
+}
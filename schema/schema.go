@@ -0,0 +1,577 @@
+// Package schema parses PostgreSQL schema dumps, such as the structure.sql
+// file Rails writes with pg_dump, into a model of tables, enum types and
+// foreign keys.
+//
+// The parser is built on pg_query, so it understands the same grammar as
+// the Postgres server itself:
+//
+//	f, _ := os.Open("db/structure.sql")
+//	s, err := schema.Parse(f)
+//	if err != nil {
+//		return err
+//	}
+//	for _, table := range s.Tables {
+//		fmt.Println(table.QualifiedName())
+//	}
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// DefaultSchema is the schema assumed for objects whose name is not
+// schema-qualified.
+const DefaultSchema = "public"
+
+// Schema is the parsed contents of a schema dump.
+//
+// Each object keeps the text of the statement that created it in its SQL
+// field and, when read by the pg_query backend, the parsed statement in its
+// Stmt field, which Deparsed turns back into SQL. Stmt is nil for objects
+// read by the regex backend.
+type Schema struct {
+	// Statements holds every statement of the dump in source order. Only
+	// the pg_query backend records them.
+	Statements  []Statement
+	Extensions  []ExtensionDef
+	Tables      []TableDef
+	Enums       []EnumDef
+	ForeignKeys []ForeignKeyDef
+	Indexes     []IndexDef
+	Sequences   []SequenceDef
+	// Domains, CompositeTypes and RangeTypes are the user-defined types
+	// other than enums.
+	Domains        []DomainDef
+	CompositeTypes []CompositeTypeDef
+	RangeTypes     []RangeTypeDef
+	// Views holds the views and materialized views, in the order the dump
+	// creates them.
+	Views []ViewDef
+	// Functions holds the functions and procedures, and Triggers the
+	// triggers, in the order the dump creates them.
+	Functions []FunctionDef
+	Triggers  []TriggerDef
+	// UnresolvedTypes lists the uses of types that are neither built in,
+	// nor defined by the dump, nor provided by an extension it installs.
+	UnresolvedTypes []TypeReference
+}
+
+// ExtensionDef is an extension installed by CREATE EXTENSION.
+type ExtensionDef struct {
+	Name   string
+	Schema string
+	// SQL is the CREATE EXTENSION statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// TableDef is a table created by CREATE TABLE.
+type TableDef struct {
+	Name        string
+	Schema      string
+	Columns     []ColumnDef
+	Constraints []string
+	// PrimaryKey lists the primary key columns, in key order.
+	PrimaryKey []string
+	// SearchPath is the search_path in effect when the table was created,
+	// which its column types are resolved along.
+	SearchPath []string
+	// Inherits lists the qualified names of the tables this table inherits
+	// from or is a partition of.
+	Inherits []string
+	// CheckFunctions lists the functions the table's CHECK constraints
+	// call, named as written, including those of AddedConstraints.
+	CheckFunctions []string
+	// AddedConstraints holds the primary key, unique, check and exclusion
+	// constraints added by ALTER TABLE after the table was created, as
+	// pg_dump does. They are also listed in Constraints, and WriteSQL
+	// writes them right after the table.
+	AddedConstraints []ConstraintDef
+	// ColumnAlterations holds the ALTER TABLE statements that set a
+	// column's default or change its identity after the table was created,
+	// as pg_dump does for columns that use a sequence, and those that alter
+	// a column the table inherits. WriteSQL writes them right after the
+	// table. Other column changes are applied to Stmt and SQL.
+	ColumnAlterations []Statement
+	// Owner is the role set by ALTER TABLE ... OWNER TO.
+	Owner string
+	// ReplicaIdentity is set by ALTER TABLE ... REPLICA IDENTITY, such as
+	// "FULL" or "USING INDEX users_email_key".
+	ReplicaIdentity string
+	// SQL is the original text of the CREATE TABLE statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// ColumnDef is a single column of a table.
+type ColumnDef struct {
+	Name string
+	// Type is the column type as pg_dump writes it, with its modifiers
+	// and array dimensions, such as "timestamp(3) with time zone" or
+	// "numeric(10,2)[]".
+	Type string
+	// BaseType is Type without modifiers or array dimensions, such as
+	// "timestamp with time zone" or "numeric", and ArrayDims the number
+	// of array dimensions.
+	BaseType  string
+	ArrayDims int
+	// UserType is the qualified name of the user-defined type BaseType
+	// resolves to, such as "billing.status", or "" for a built-in type.
+	UserType  string
+	IsNotNull bool
+	// Default is the default expression, as the deparser writes it, such
+	// as "now()" or "'{}'::jsonb".
+	Default string
+	// DefaultFunctions lists the functions the default calls, named as
+	// written, and DefaultSequences the qualified names of the sequences
+	// it passes to nextval, currval or setval.
+	DefaultFunctions []string
+	DefaultSequences []string
+	Constraint       string
+	// Identity is "ALWAYS" or "BY DEFAULT" for identity columns.
+	Identity string
+	// Sequence is the qualified name of the sequence the column takes its
+	// values from: the one its nextval default calls, or the one Postgres
+	// creates for a serial or identity column.
+	Sequence string
+	Pos      Position
+}
+
+// ConstraintDef is a primary key, unique, check or exclusion constraint
+// added with ALTER TABLE ... ADD CONSTRAINT.
+type ConstraintDef struct {
+	Name   string
+	Schema string
+	Table  string
+	// Type is "PRIMARY KEY", "UNIQUE", "CHECK" or "EXCLUDE".
+	Type string
+	// Columns lists the key columns of a primary key or unique constraint.
+	Columns []string
+	// Definition is the constraint as it appears in TableDef.Constraints,
+	// such as "CONSTRAINT users_pkey PRIMARY KEY (id)".
+	Definition string
+	// Functions lists the functions a CHECK constraint calls, named as
+	// written.
+	Functions []string
+	// SQL is the ALTER TABLE statement that adds the constraint.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// EnumDef is a type created by CREATE TYPE ... AS ENUM.
+type EnumDef struct {
+	Name   string
+	Schema string
+	Values []string
+	// SearchPath is the search_path in effect when the enum was created,
+	// or nil when not known.
+	SearchPath []string
+	// SQL is the original text of the CREATE TYPE statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// DomainDef is a type created by CREATE DOMAIN.
+type DomainDef struct {
+	Name   string
+	Schema string
+	// Type is the underlying type as pg_dump writes it, with modifiers,
+	// and BaseType the same without modifiers or array dimensions.
+	Type     string
+	BaseType string
+	// UserType is the qualified name of the user-defined type BaseType
+	// resolves to, or "" for a built-in type.
+	UserType string
+	NotNull  bool
+	Default  string
+	// Checks lists the CHECK constraints, such as
+	// "CONSTRAINT email_check CHECK ((VALUE ~ '@'::text))".
+	Checks []string
+	// Functions lists the functions the default and CHECK constraints
+	// call, named as written.
+	Functions []string
+	// SearchPath is the search_path in effect when the domain was
+	// created.
+	SearchPath []string
+	// SQL is the original text of the CREATE DOMAIN statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// CompositeTypeDef is a type created by CREATE TYPE ... AS (...).
+type CompositeTypeDef struct {
+	Name   string
+	Schema string
+	// Attributes holds the fields of the type. Only their names and types
+	// are set.
+	Attributes []ColumnDef
+	// SearchPath is the search_path in effect when the type was created.
+	SearchPath []string
+	// SQL is the original text of the CREATE TYPE statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// RangeTypeDef is a type created by CREATE TYPE ... AS RANGE.
+type RangeTypeDef struct {
+	Name   string
+	Schema string
+	// Subtype is the type of the range's bounds, as pg_dump writes it.
+	Subtype string
+	// UserType is the qualified name of the user-defined type Subtype
+	// resolves to, or "" for a built-in type.
+	UserType string
+	// SearchPath is the search_path in effect when the type was created.
+	SearchPath []string
+	// SQL is the original text of the CREATE TYPE statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// ViewDef is a view created by CREATE VIEW, or a materialized view created
+// by CREATE MATERIALIZED VIEW.
+type ViewDef struct {
+	Name         string
+	Schema       string
+	Materialized bool
+	// Relations lists the qualified names of the tables and views the
+	// view's query reads from, in the order they first appear.
+	Relations []string
+	// Columns lists the columns of those relations the query uses, as
+	// "schema.table.column".
+	Columns []string
+	// Functions lists the functions the query calls, named as written.
+	Functions []string
+	// SearchPath is the search_path in effect when the view was created.
+	SearchPath []string
+	// SQL is the original text of the CREATE VIEW statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// FunctionDef is a function or procedure created by CREATE FUNCTION or
+// CREATE PROCEDURE. Overloads are separate FunctionDefs of the same name.
+type FunctionDef struct {
+	Name   string
+	Schema string
+	// Arguments is the argument list as written, such as
+	// "user_id bigint, VARIADIC tags text[]".
+	Arguments string
+	// ReturnType is the declared result type, or "" for a procedure.
+	ReturnType string
+	Language   string
+	// Body is the function's source code as written between quotes, or ""
+	// for a SQL-standard body (BEGIN ATOMIC or RETURN).
+	Body string
+	// SearchPath is the search_path the function's body runs with: the
+	// one it sets with SET search_path, or DefaultSearchPath. The dump's
+	// own search_path does not apply when the function runs.
+	SearchPath []string
+	// DumpSearchPath is the search_path in effect where the dump creates
+	// the function, which WriteSQL sets again before creating it.
+	DumpSearchPath []string
+	// Tables lists the qualified names of the tables and views the
+	// function reads or writes, Sequences the sequences it uses and Types
+	// the user-defined types of its arguments, result and body, each in
+	// the order they first appear. Only objects the dump defines are
+	// listed.
+	Tables    []string
+	Sequences []string
+	Types     []string
+	// Functions lists the functions the body calls, named as written.
+	Functions []string
+	// SQL is the original text of the CREATE FUNCTION statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// TriggerDef is a trigger created by CREATE TRIGGER.
+type TriggerDef struct {
+	Name   string
+	Schema string
+	Table  string
+	// Function is the trigger function as written after EXECUTE FUNCTION.
+	Function string
+	// SearchPath is the search_path in effect where the dump creates the
+	// trigger, along which Function is looked up.
+	SearchPath []string
+	// SQL is the original text of the CREATE TRIGGER statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// ForeignKeyDef is a foreign key constraint added with
+// ALTER TABLE ... ADD CONSTRAINT ... FOREIGN KEY.
+type ForeignKeyDef struct {
+	Name       string
+	Schema     string
+	Table      string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	// OnDelete and OnUpdate are the referential actions: "NO ACTION",
+	// "RESTRICT", "CASCADE", "SET NULL" or "SET DEFAULT".
+	OnDelete string
+	OnUpdate string
+	// Match is the match type: "SIMPLE", "FULL" or "PARTIAL".
+	Match             string
+	Deferrable        bool
+	InitiallyDeferred bool
+	// NotValid is set for constraints added with NOT VALID, which existing
+	// rows have not been checked against.
+	NotValid bool
+	// SearchPath is the search_path in effect where the dump adds the
+	// constraint, or nil when not known.
+	SearchPath []string
+	// SQL is the ALTER TABLE statement that adds the constraint.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// SequenceDef is a sequence created by CREATE SEQUENCE.
+type SequenceDef struct {
+	Name   string
+	Schema string
+	// OwnedBy is the column the sequence belongs to, as
+	// "schema.table.column", or "" if it belongs to none.
+	OwnedBy string
+	// SearchPath is the search_path in effect when the sequence was
+	// created, or nil when not known.
+	SearchPath []string
+	// SQL is the original text of the CREATE SEQUENCE statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+	// Ownership is the ALTER SEQUENCE ... OWNED BY statement pg_dump
+	// writes once the owning table exists. Its SQL is empty if the dump
+	// has none.
+	Ownership Statement
+}
+
+// IndexDef is an index created by CREATE INDEX.
+type IndexDef struct {
+	Name string
+	// Schema and Table name the indexed table. The index lives in the
+	// same schema.
+	Schema string
+	Table  string
+	Unique bool
+	// Method is the access method, such as "btree", "gin", "gist" or
+	// "brin".
+	Method string
+	// Columns lists the index keys in order.
+	Columns []IndexColumn
+	// Include lists the columns of the INCLUDE clause.
+	Include []string
+	// Where is the predicate of a partial index, or "".
+	Where string
+	// SearchPath is the search_path in effect when the index was created,
+	// or nil when not known.
+	SearchPath []string
+	// SQL is the original text of the CREATE INDEX statement.
+	SQL string
+	Pos Position
+	// Stmt is the parsed statement.
+	Stmt *pg_query.Node
+}
+
+// IndexColumn is a single key of an index.
+type IndexColumn struct {
+	// Name is the indexed column, or "" for an expression.
+	Name string
+	// Expression is the indexed expression, or "" for a column.
+	Expression string
+	// Opclass is the operator class, such as "gin_trgm_ops", or "" for
+	// the default one.
+	Opclass    string
+	Descending bool
+}
+
+// QualifiedName joins a schema and an object name as "schema.name".
+func QualifiedName(schema, name string) string {
+	if schema == "" {
+		schema = DefaultSchema
+	}
+	return fmt.Sprintf("%s.%s", schema, name)
+}
+
+var plainIdent = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+
+// QuoteIdent double-quotes name unless it is a plain lower-case
+// identifier.
+func QuoteIdent(name string) string {
+	if plainIdent.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuotedName is QualifiedName with each part quoted as needed, for use in
+// generated SQL.
+func QuotedName(schema, name string) string {
+	if schema == "" {
+		schema = DefaultSchema
+	}
+	return QuoteIdent(schema) + "." + QuoteIdent(name)
+}
+
+// QualifiedName returns the table name as "schema.name".
+func (t TableDef) QualifiedName() string {
+	return QualifiedName(t.Schema, t.Name)
+}
+
+// Column returns the column with the given name, or nil if the table has
+// no such column.
+func (t *TableDef) Column(name string) *ColumnDef {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// IsPrimaryKey reports whether column is part of the table's primary key.
+func (t TableDef) IsPrimaryKey(column string) bool {
+	for _, key := range t.PrimaryKey {
+		if key == column {
+			return true
+		}
+	}
+	return false
+}
+
+// AddConstraint folds a constraint added by ALTER TABLE into the table: it
+// is appended to AddedConstraints and Constraints, the functions a check
+// calls to CheckFunctions, a primary key sets PrimaryKey, and a
+// single-column primary key or unique constraint sets the column's
+// Constraint.
+func (t *TableDef) AddConstraint(c ConstraintDef) {
+	t.AddedConstraints = append(t.AddedConstraints, c)
+	t.Constraints = append(t.Constraints, c.Definition)
+	for _, function := range c.Functions {
+		t.CheckFunctions = appendUnique(t.CheckFunctions, function)
+	}
+	if c.Type == "PRIMARY KEY" {
+		t.PrimaryKey = c.Columns
+	}
+	if len(c.Columns) == 1 && (c.Type == "PRIMARY KEY" || c.Type == "UNIQUE") {
+		if col := t.Column(c.Columns[0]); col != nil && col.Constraint == "" {
+			col.Constraint = c.Type
+		}
+	}
+}
+
+// TableName returns the qualified name of the table the constraint is on.
+func (c ConstraintDef) TableName() string {
+	return QualifiedName(c.Schema, c.Table)
+}
+
+// QualifiedName returns the enum name as "schema.name".
+func (e EnumDef) QualifiedName() string {
+	return QualifiedName(e.Schema, e.Name)
+}
+
+// FromTable returns the qualified name of the referencing table.
+func (fk ForeignKeyDef) FromTable() string {
+	return QualifiedName(fk.Schema, fk.Table)
+}
+
+// ToTable returns the qualified name of the referenced table.
+func (fk ForeignKeyDef) ToTable() string {
+	return QualifiedName(fk.RefSchema, fk.RefTable)
+}
+
+// Options returns the clauses of the constraint that differ from the
+// defaults, as they would follow REFERENCES in SQL, such as
+// "MATCH FULL ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED".
+func (fk ForeignKeyDef) Options() string {
+	var clauses []string
+	if fk.Match != "" && fk.Match != "SIMPLE" {
+		clauses = append(clauses, "MATCH "+fk.Match)
+	}
+	if fk.OnUpdate != "" && fk.OnUpdate != "NO ACTION" {
+		clauses = append(clauses, "ON UPDATE "+fk.OnUpdate)
+	}
+	if fk.OnDelete != "" && fk.OnDelete != "NO ACTION" {
+		clauses = append(clauses, "ON DELETE "+fk.OnDelete)
+	}
+	if fk.Deferrable {
+		clauses = append(clauses, "DEFERRABLE")
+	}
+	if fk.InitiallyDeferred {
+		clauses = append(clauses, "INITIALLY DEFERRED")
+	}
+	if fk.NotValid {
+		clauses = append(clauses, "NOT VALID")
+	}
+	return strings.Join(clauses, " ")
+}
+
+// TableName returns the qualified name of the indexed table.
+func (i IndexDef) TableName() string {
+	return QualifiedName(i.Schema, i.Table)
+}
+
+// ColumnNames returns the names of the indexed columns and INCLUDE columns.
+// It reports false if the index has an expression key.
+func (i IndexDef) ColumnNames() ([]string, bool) {
+	var names []string
+	for _, col := range i.Columns {
+		if col.Name == "" {
+			return nil, false
+		}
+		names = append(names, col.Name)
+	}
+	return append(names, i.Include...), true
+}
+
+// Table returns the table with the given qualified name, or nil if the
+// schema has no such table.
+func (s *Schema) Table(name string) *TableDef {
+	for i := range s.Tables {
+		if s.Tables[i].QualifiedName() == name {
+			return &s.Tables[i]
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum type with the given qualified name, or nil if the
+// schema has no such type.
+func (s *Schema) Enum(name string) *EnumDef {
+	for i := range s.Enums {
+		if s.Enums[i].QualifiedName() == name {
+			return &s.Enums[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package schema
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern matches qualified object names. It is written either as a glob,
+// such as "billing.*" or "public.submissions_*", or as a regular expression
+// between slashes, such as "/^(billing|ledger)\..*_events$/". A glob
+// without a schema, such as "users", matches in the public schema. Regular
+// expressions are matched against the whole "schema.name" string.
+type Pattern struct {
+	text string
+	glob string
+	re   *regexp.Regexp
+}
+
+// ParsePattern compiles a glob or /regex/ pattern.
+func ParsePattern(text string) (Pattern, error) {
+	if len(text) >= 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid pattern %q: %v", text, err)
+		}
+		return Pattern{text: text, re: re}, nil
+	}
+
+	glob := qualifyName(text)
+	if _, err := path.Match(glob, ""); err != nil {
+		return Pattern{}, fmt.Errorf("invalid pattern %q: %v", text, err)
+	}
+	return Pattern{text: text, glob: glob}, nil
+}
+
+// Match reports whether the qualified name matches the pattern.
+func (p Pattern) Match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
+
+// String returns the pattern as it was written.
+func (p Pattern) String() string {
+	return p.text
+}
+
+// Selector picks objects whose qualified name matches at least one include
+// pattern and no exclude pattern.
+type Selector struct {
+	Include []Pattern
+	Exclude []Pattern
+}
+
+// NewSelector compiles include and exclude patterns into a Selector.
+func NewSelector(include, exclude []string) (*Selector, error) {
+	sel := &Selector{}
+	for _, text := range include {
+		pattern, err := ParsePattern(text)
+		if err != nil {
+			return nil, err
+		}
+		sel.Include = append(sel.Include, pattern)
+	}
+	for _, text := range exclude {
+		pattern, err := ParsePattern(text)
+		if err != nil {
+			return nil, err
+		}
+		sel.Exclude = append(sel.Exclude, pattern)
+	}
+	return sel, nil
+}
+
+// PrefixPattern returns the glob that selects the public tables named
+// <prefix>_*.
+func PrefixPattern(prefix string) string {
+	return fmt.Sprintf("%s.%s_*", DefaultSchema, prefix)
+}
+
+// Match reports whether the qualified name is selected.
+func (sel *Selector) Match(name string) bool {
+	return sel.Includes(name) && !sel.Excludes(name)
+}
+
+// Includes reports whether the qualified name matches an include pattern.
+func (sel *Selector) Includes(name string) bool {
+	for _, pattern := range sel.Include {
+		if pattern.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Excludes reports whether the qualified name matches an exclude pattern.
+func (sel *Selector) Excludes(name string) bool {
+	for _, pattern := range sel.Exclude {
+		if pattern.Match(name) {
+			return true
+		}
+	}
+	return false
+}
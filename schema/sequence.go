@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// serialTypes are the column types that make Postgres create a sequence
+// for the column.
+var serialTypes = map[string]bool{
+	"smallserial": true,
+	"serial2":     true,
+	"serial":      true,
+	"serial4":     true,
+	"bigserial":   true,
+	"serial8":     true,
+}
+
+// identityKinds maps Constraint.GeneratedWhen to the SQL keywords of an
+// identity column.
+var identityKinds = map[string]string{
+	"a": "ALWAYS",
+	"d": "BY DEFAULT",
+}
+
+// QualifiedName returns the sequence name as "schema.name".
+func (seq SequenceDef) QualifiedName() string {
+	return QualifiedName(seq.Schema, seq.Name)
+}
+
+// OwnerTable returns the qualified name of the table whose column owns the
+// sequence, or "" if it has no owner.
+func (seq SequenceDef) OwnerTable() string {
+	if i := strings.LastIndex(seq.OwnedBy, "."); i >= 0 {
+		return seq.OwnedBy[:i]
+	}
+	return ""
+}
+
+// Sequence returns the sequence with the given qualified name, or nil if
+// the schema has no such sequence.
+func (s *Schema) Sequence(name string) *SequenceDef {
+	for i := range s.Sequences {
+		if s.Sequences[i].QualifiedName() == name {
+			return &s.Sequences[i]
+		}
+	}
+	return nil
+}
+
+// UsedSequences returns the sequences the columns of tables take their
+// values from or use in their defaults, and the sequences functions use,
+// in the order they are first used. Sequences that serial and identity
+// columns create themselves are not in s, so they are left out.
+func (s *Schema) UsedSequences(tables []TableDef, functions []FunctionDef) []SequenceDef {
+	var names []string
+	for _, table := range tables {
+		for _, col := range table.Columns {
+			names = append(append(names, col.Sequence), col.DefaultSequences...)
+		}
+	}
+	for _, fn := range functions {
+		names = append(names, fn.Sequences...)
+	}
+
+	var used []SequenceDef
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		if seq := s.Sequence(name); seq != nil {
+			used = append(used, *seq)
+			seen[name] = true
+		}
+	}
+	return used
+}
+
+// addColumn appends col to the table, adding it to the primary key if it
+// is declared as one, and links serial and identity columns to the
+// sequence Postgres creates for them unless the column names one.
+func (t *TableDef) addColumn(col ColumnDef) {
+	if col.Sequence == "" && (serialTypes[strings.ToLower(col.Type)] || col.Identity != "") {
+		col.Sequence = t.implicitSequence(col.Name)
+	}
+	t.Columns = append(t.Columns, col)
+	if col.Constraint == "PRIMARY KEY" {
+		t.PrimaryKey = append(t.PrimaryKey, col.Name)
+	}
+}
+
+// implicitSequence returns the qualified name of the sequence Postgres
+// creates for a serial or identity column of the table.
+func (t TableDef) implicitSequence(column string) string {
+	return QualifiedName(t.Schema, fmt.Sprintf("%s_%s_seq", t.Name, column))
+}
+
+// ParseCreateSequence converts a CREATE SEQUENCE statement into a
+// SequenceDef. The SQL field is left empty.
+func ParseCreateSequence(stmt *pg_query.CreateSeqStmt) SequenceDef {
+	seq := SequenceDef{
+		Name:   stmt.GetSequence().GetRelname(),
+		Schema: DefaultSchema,
+		Stmt:   &pg_query.Node{Node: &pg_query.Node_CreateSeqStmt{CreateSeqStmt: stmt}},
+	}
+	if schema := stmt.GetSequence().GetSchemaname(); schema != "" {
+		seq.Schema = schema
+	}
+	if owner, ok := ownedBy(stmt.GetOptions()); ok {
+		seq.OwnedBy = owner
+	}
+	return seq
+}
+
+// ApplyAlterSequence records the owning column set by an
+// ALTER SEQUENCE ... OWNED BY statement on the sequence it names, keeping
+// stmt as the sequence's Ownership. Other ALTER SEQUENCE statements, and
+// statements on sequences s does not define, are ignored.
+func (s *Schema) ApplyAlterSequence(stmt Statement) {
+	alter := stmt.Node.GetAlterSeqStmt()
+	seq := s.Sequence(getTableName(alter.GetSequence()))
+	if seq == nil {
+		return
+	}
+	if owner, ok := ownedBy(alter.GetOptions()); ok {
+		seq.OwnedBy = owner
+		seq.Ownership = stmt
+	}
+}
+
+// ownedBy returns the column an OWNED BY option names, as
+// "schema.table.column", or "" for OWNED BY NONE. It reports false if
+// options has no OWNED BY.
+func ownedBy(options []*pg_query.Node) (string, bool) {
+	for _, option := range options {
+		defElem := option.GetDefElem()
+		if defElem.GetDefname() != "owned_by" {
+			continue
+		}
+		names := stringList(defElem.GetArg().GetList().GetItems())
+		switch len(names) {
+		case 2:
+			return QualifiedName(DefaultSchema, names[0]) + "." + names[1], true
+		case 3:
+			return strings.Join(names, "."), true
+		}
+		// OWNED BY NONE
+		return "", true
+	}
+	return "", false
+}
+
+// sequenceFunctions are the functions whose first argument names a
+// sequence.
+var sequenceFunctions = map[string]bool{
+	"nextval": true,
+	"currval": true,
+	"setval":  true,
+}
+
+// nextvalSequence returns the qualified name of the sequence a default
+// such as nextval('users_id_seq'::regclass) draws from, or "" if expr is
+// not a call to nextval.
+func nextvalSequence(expr *pg_query.Node) string {
+	call := expr.GetFuncCall()
+	names := stringList(call.GetFuncname())
+	if len(names) == 0 || names[len(names)-1] != "nextval" {
+		return ""
+	}
+	return sequenceArgument(call)
+}
+
+// sequenceArgument returns the qualified name of the sequence passed to a
+// call of nextval, currval or setval, or "" for any other call.
+func sequenceArgument(call *pg_query.FuncCall) string {
+	name := sequenceArgumentName(call)
+	if name == "" {
+		return ""
+	}
+	return qualifyName(name)
+}
+
+// sequenceArgumentName returns the sequence name passed to a call of
+// nextval, currval or setval as written, without quotes, or "" for any
+// other call.
+func sequenceArgumentName(call *pg_query.FuncCall) string {
+	names := stringList(call.GetFuncname())
+	if len(names) == 0 || !sequenceFunctions[names[len(names)-1]] || len(call.GetArgs()) == 0 {
+		return ""
+	}
+	arg := call.GetArgs()[0]
+	if cast := arg.GetTypeCast(); cast != nil {
+		arg = cast.GetArg()
+	}
+	return strings.ReplaceAll(arg.GetAConst().GetSval().GetSval(), `"`, "")
+}
+
+// identitySequence returns the qualified name an identity constraint gives
+// its sequence with SEQUENCE NAME, or "".
+func identitySequence(constraint *pg_query.Constraint) string {
+	for _, option := range constraint.GetOptions() {
+		if defElem := option.GetDefElem(); defElem.GetDefname() == "sequence_name" {
+			names := stringList(defElem.GetArg().GetList().GetItems())
+			if len(names) > 0 {
+				return qualifyName(strings.Join(names, "."))
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// Stub returns a copy of table cut down to its primary key columns and the
+// named columns. The stub keeps the primary key constraint, added by ALTER
+// TABLE if the table's is, and any unique constraint over kept columns, so
+// foreign keys can still reference it, but drops defaults, identities and
+// all other constraints so it does not depend on sequences, functions or
+// other tables.
+//
+// When table has a parsed statement the stub is built from it, and its SQL
+// is deparsed from the trimmed statement. Otherwise the SQL is generated
+// from the column definitions.
+func Stub(table TableDef, columns []string) (TableDef, error) {
+	keep := make(map[string]bool)
+	for _, column := range table.PrimaryKey {
+		keep[column] = true
+	}
+	for _, column := range columns {
+		keep[column] = true
+	}
+
+	stub := table
+	stub.Columns = nil
+	stub.Constraints = nil
+	stub.AddedConstraints = nil
+	stub.ColumnAlterations = nil
+	stub.Inherits = nil
+	stub.CheckFunctions = nil
+	for _, col := range table.Columns {
+		if keep[col.Name] {
+			col.Default = ""
+			col.DefaultFunctions = nil
+			col.DefaultSequences = nil
+			col.Identity = ""
+			col.Sequence = ""
+			stub.Columns = append(stub.Columns, col)
+		}
+	}
+	addedPrimaryKey := hasAddedPrimaryKey(table)
+	if len(table.PrimaryKey) > 0 && !addedPrimaryKey {
+		stub.Constraints = append(stub.Constraints, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(table.PrimaryKey, ", ")))
+	}
+
+	// The primary key and unique constraints added by ALTER TABLE are kept
+	// as they are, so the primary key keeps its name
+	for _, c := range table.AddedConstraints {
+		if c.Type == "PRIMARY KEY" || (c.Type == "UNIQUE" && allKept(c.Columns, keep)) {
+			stub.AddedConstraints = append(stub.AddedConstraints, c)
+			stub.Constraints = append(stub.Constraints, c.Definition)
+		}
+	}
+
+	create := table.Stmt.GetCreateStmt()
+	if create == nil {
+		stub.Stmt = nil
+		stub.SQL = stubSQL(stub)
+		return stub, nil
+	}
+
+	stubStmt := &pg_query.CreateStmt{Relation: create.Relation}
+	hasPrimaryKey := false
+	for _, element := range create.TableElts {
+		if def := element.GetColumnDef(); def != nil {
+			if !keep[def.Colname] {
+				continue
+			}
+			stubDef := &pg_query.ColumnDef{
+				Colname:    def.Colname,
+				TypeName:   def.TypeName,
+				IsLocal:    true,
+				CollClause: def.CollClause,
+			}
+			for _, constraint := range def.Constraints {
+				switch constraint.GetConstraint().GetContype() {
+				case pg_query.ConstrType_CONSTR_PRIMARY:
+					hasPrimaryKey = true
+					stubDef.Constraints = append(stubDef.Constraints, constraint)
+				case pg_query.ConstrType_CONSTR_NOTNULL, pg_query.ConstrType_CONSTR_UNIQUE:
+					stubDef.Constraints = append(stubDef.Constraints, constraint)
+				}
+			}
+			stubStmt.TableElts = append(stubStmt.TableElts, &pg_query.Node{Node: &pg_query.Node_ColumnDef{ColumnDef: stubDef}})
+			continue
+		}
+
+		constraint := element.GetConstraint()
+		switch constraint.GetContype() {
+		case pg_query.ConstrType_CONSTR_PRIMARY:
+			hasPrimaryKey = true
+			stubStmt.TableElts = append(stubStmt.TableElts, element)
+		case pg_query.ConstrType_CONSTR_UNIQUE:
+			if allKept(stringList(constraint.Keys), keep) {
+				stubStmt.TableElts = append(stubStmt.TableElts, element)
+			}
+		}
+	}
+
+	// Fall back to a primary key built from the model if neither the
+	// CREATE TABLE nor an ALTER TABLE declares one
+	if !hasPrimaryKey && !addedPrimaryKey && len(table.PrimaryKey) > 0 {
+		primaryKey := &pg_query.Constraint{Contype: pg_query.ConstrType_CONSTR_PRIMARY}
+		for _, column := range table.PrimaryKey {
+			primaryKey.Keys = append(primaryKey.Keys, &pg_query.Node{Node: &pg_query.Node_String_{String_: &pg_query.String{Sval: column}}})
+		}
+		stubStmt.TableElts = append(stubStmt.TableElts, &pg_query.Node{Node: &pg_query.Node_Constraint{Constraint: primaryKey}})
+	}
+
+	stub.Stmt = &pg_query.Node{Node: &pg_query.Node_CreateStmt{CreateStmt: stubStmt}}
+	sql, err := Deparse(stub.Stmt)
+	if err != nil {
+		return TableDef{}, fmt.Errorf("error deparsing stub of %s: %v", table.QualifiedName(), err)
+	}
+	stub.SQL = sql
+	return stub, nil
+}
+
+// hasAddedPrimaryKey reports whether the primary key of table is added by
+// ALTER TABLE rather than declared in its CREATE TABLE.
+func hasAddedPrimaryKey(table TableDef) bool {
+	for _, c := range table.AddedConstraints {
+		if c.Type == "PRIMARY KEY" {
+			return true
+		}
+	}
+	return false
+}
+
+func allKept(columns []string, keep map[string]bool) bool {
+	for _, column := range columns {
+		if !keep[column] {
+			return false
+		}
+	}
+	return true
+}
+
+// stubSQL writes the CREATE TABLE statement for a stub from its column
+// definitions.
+func stubSQL(stub TableDef) string {
+	var lines []string
+	for _, col := range stub.Columns {
+		line := fmt.Sprintf("    %s %s", QuoteIdent(col.Name), col.Type)
+		if col.IsNotNull {
+			line += " NOT NULL"
+		}
+		lines = append(lines, line)
+	}
+	if len(stub.PrimaryKey) > 0 && !hasAddedPrimaryKey(stub) {
+		var keys []string
+		for _, key := range stub.PrimaryKey {
+			keys = append(keys, QuoteIdent(key))
+		}
+		lines = append(lines, fmt.Sprintf("    PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("CREATE TABLE %s ();", QuotedName(stub.Schema, stub.Name))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", QuotedName(stub.Schema, stub.Name), strings.Join(lines, ",\n"))
+}
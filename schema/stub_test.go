@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStub(t *testing.T) {
+	const dump = `CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email text NOT NULL,
+    name text,
+    org_id bigint
+);
+
+CREATE SEQUENCE public.users_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_name_key UNIQUE (name);
+`
+	s, err := ParseRegex(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseRegex() error = %v", err)
+	}
+	table := s.Table("public.users")
+	if table == nil {
+		t.Fatal("public.users not found")
+	}
+	if table.Columns[0].Default == "" || len(table.AddedConstraints) != 3 {
+		t.Fatalf("public.users = %+v, want a default on id and three added constraints", table)
+	}
+
+	stub, err := Stub(*table, []string{"email"})
+	if err != nil {
+		t.Fatalf("Stub() error = %v", err)
+	}
+	var columns []string
+	for _, col := range stub.Columns {
+		columns = append(columns, col.Name)
+		if col.Default != "" || col.Sequence != "" {
+			t.Errorf("column %s keeps its default %q and sequence %q", col.Name, col.Default, col.Sequence)
+		}
+	}
+	if want := []string{"id", "email"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("columns = %q, want %q", columns, want)
+	}
+
+	var added []string
+	for _, c := range stub.AddedConstraints {
+		added = append(added, c.Name)
+	}
+	if want := []string{"users_pkey", "users_email_key"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added constraints = %q, want %q", added, want)
+	}
+	if len(stub.ColumnAlterations) != 0 {
+		t.Errorf("column alterations = %d, want 0", len(stub.ColumnAlterations))
+	}
+	// The primary key is added by ALTER TABLE, so declaring it in the
+	// CREATE TABLE too would fail
+	if strings.Contains(stub.SQL, "PRIMARY KEY") || strings.Contains(stub.SQL, "nextval") {
+		t.Errorf("stub SQL = %q, want the columns only", stub.SQL)
+	}
+}
@@ -0,0 +1,46 @@
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE TYPE public.status AS ENUM (
+    'active',
+    'archived'
+);
+
+SET default_tablespace = '';
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email character varying(255) NOT NULL,
+    status public.status DEFAULT 'active'::public.status,
+    created_at timestamp(6) without time zone NOT NULL
+);
+
+CREATE SEQUENCE public.users_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+CREATE TABLE public.posts (
+    id bigint NOT NULL,
+    user_id bigint,
+    title text,
+    tags character varying[]
+);
+
+ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.posts
+    ADD CONSTRAINT posts_pkey PRIMARY KEY (id);
+
+CREATE UNIQUE INDEX index_users_on_email ON public.users USING btree (email);
+
+ALTER TABLE ONLY public.posts
+    ADD CONSTRAINT fk_rails_posts_user FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
@@ -0,0 +1,140 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// typeNames maps the internal names of built-in types, and the aliases
+// SQL accepts for them, to the names pg_dump writes.
+var typeNames = map[string]string{
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int":         "integer",
+	"int8":        "bigint",
+	"float4":      "real",
+	"float8":      "double precision",
+	"float":       "double precision",
+	"decimal":     "numeric",
+	"bool":        "boolean",
+	"varchar":     "character varying",
+	"bpchar":      "character",
+	"varbit":      "bit varying",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+	"time":        "time without time zone",
+	"timetz":      "time with time zone",
+}
+
+// CanonicalTypeName returns the name pg_dump writes for a built-in type
+// given by its internal name or an alias, with or without the pg_catalog
+// schema: "integer" for "int4", "pg_catalog.int4" or "int". Other names
+// are returned unchanged, except that a pg_catalog prefix is dropped.
+func CanonicalTypeName(name string) string {
+	name = strings.TrimPrefix(name, "pg_catalog.")
+	if canonical, ok := typeNames[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// intervalFields maps the typmod pg_query gives an interval with a field
+// restriction, a bit mask of the fields, to the SQL that restricts it. An
+// interval without one gets the mask of every field, which is not listed.
+var intervalFields = map[int32]string{
+	1 << 2:                       "year",
+	1 << 1:                       "month",
+	1 << 3:                       "day",
+	1 << 10:                      "hour",
+	1 << 11:                      "minute",
+	1 << 12:                      "second",
+	1<<2 | 1<<1:                  "year to month",
+	1<<3 | 1<<10:                 "day to hour",
+	1<<3 | 1<<10 | 1<<11:         "day to minute",
+	1<<3 | 1<<10 | 1<<11 | 1<<12: "day to second",
+	1<<10 | 1<<11:                "hour to minute",
+	1<<10 | 1<<11 | 1<<12:        "hour to second",
+	1<<11 | 1<<12:                "minute to second",
+}
+
+// formatTypeName writes a type the way pg_dump does, with its modifiers
+// and array dimensions, such as "timestamp(3) with time zone",
+// "interval day to second(3)" or "integer[][]". It also returns the base
+// type, the canonical name without modifiers or array dimensions, and the
+// number of array dimensions.
+func formatTypeName(typeName *pg_query.TypeName) (typ, base string, dims int) {
+	names := stringList(typeName.GetNames())
+	if len(names) == 0 {
+		return "", "", 0
+	}
+	base = strings.Join(names, ".")
+	if len(names) == 1 || names[0] == "pg_catalog" {
+		base = CanonicalTypeName(names[len(names)-1])
+	}
+
+	var modifiers []string
+	for _, mod := range typeName.GetTypmods() {
+		switch {
+		case mod.GetAConst().GetIval() != nil:
+			modifiers = append(modifiers, fmt.Sprintf("%d", mod.GetAConst().GetIval().GetIval()))
+		case mod.GetAConst().GetSval() != nil:
+			modifiers = append(modifiers, mod.GetAConst().GetSval().GetSval())
+		case mod.GetColumnRef() != nil:
+			modifiers = append(modifiers, strings.Join(stringList(mod.GetColumnRef().GetFields()), "."))
+		}
+	}
+
+	typ = base
+	switch {
+	case base == "interval" && len(modifiers) > 0:
+		// The first modifier is the field mask, the second the precision
+		if fields, ok := intervalFields[typeName.GetTypmods()[0].GetAConst().GetIval().GetIval()]; ok {
+			typ += " " + fields
+		}
+		if len(modifiers) > 1 {
+			typ += fmt.Sprintf("(%s)", modifiers[1])
+		}
+	case strings.HasPrefix(base, "timestamp ") || strings.HasPrefix(base, "time "):
+		// The precision goes before the time zone
+		if len(modifiers) > 0 {
+			name, zone, _ := strings.Cut(base, " ")
+			typ = fmt.Sprintf("%s(%s) %s", name, strings.Join(modifiers, ","), zone)
+		}
+	case len(modifiers) > 0:
+		typ += fmt.Sprintf("(%s)", strings.Join(modifiers, ","))
+	}
+
+	for _, bound := range typeName.GetArrayBounds() {
+		if size := bound.GetInteger().GetIval(); size > 0 {
+			typ += fmt.Sprintf("[%d]", size)
+		} else {
+			typ += "[]"
+		}
+	}
+	return typ, base, len(typeName.GetArrayBounds())
+}
+
+var (
+	arrayDimension   = regexp.MustCompile(`\[\d*\]$`)
+	typeModifierList = regexp.MustCompile(`\s*\([^)]*\)`)
+	intervalRange    = regexp.MustCompile(`^interval (?:year|month|day|hour|minute|second)(?: to (?:month|hour|minute|second))?$`)
+)
+
+// splitTypeText splits a type written as SQL, such as
+// "character varying(255)[]", into its base type, "character varying",
+// and its number of array dimensions.
+func splitTypeText(typ string) (base string, dims int) {
+	base = strings.TrimSpace(typ)
+	for arrayDimension.MatchString(base) {
+		base = strings.TrimSpace(arrayDimension.ReplaceAllString(base, ""))
+		dims++
+	}
+	base = typeModifierList.ReplaceAllString(base, "")
+	if intervalRange.MatchString(base) {
+		base = "interval"
+	}
+	return CanonicalTypeName(base), dims
+}
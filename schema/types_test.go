@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"testing"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+func strNode(s string) *pg_query.Node {
+	return &pg_query.Node{Node: &pg_query.Node_String_{String_: &pg_query.String{Sval: s}}}
+}
+
+func intNode(i int32) *pg_query.Node {
+	return &pg_query.Node{Node: &pg_query.Node_AConst{AConst: &pg_query.A_Const{Val: &pg_query.A_Const_Ival{Ival: &pg_query.Integer{Ival: i}}}}}
+}
+
+func arrayBound(size int32) *pg_query.Node {
+	return &pg_query.Node{Node: &pg_query.Node_Integer{Integer: &pg_query.Integer{Ival: size}}}
+}
+
+func TestFormatTypeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeName *pg_query.TypeName
+		typ      string
+		base     string
+		dims     int
+	}{
+		{
+			name:     "alias",
+			typeName: &pg_query.TypeName{Names: []*pg_query.Node{strNode("int")}},
+			typ:      "integer",
+			base:     "integer",
+		},
+		{
+			name:     "internal name",
+			typeName: &pg_query.TypeName{Names: []*pg_query.Node{strNode("pg_catalog"), strNode("int8")}},
+			typ:      "bigint",
+			base:     "bigint",
+		},
+		{
+			name: "length",
+			typeName: &pg_query.TypeName{
+				Names:   []*pg_query.Node{strNode("pg_catalog"), strNode("varchar")},
+				Typmods: []*pg_query.Node{intNode(255)},
+			},
+			typ:  "character varying(255)",
+			base: "character varying",
+		},
+		{
+			name: "precision before time zone",
+			typeName: &pg_query.TypeName{
+				Names:   []*pg_query.Node{strNode("pg_catalog"), strNode("timestamptz")},
+				Typmods: []*pg_query.Node{intNode(3)},
+			},
+			typ:  "timestamp(3) with time zone",
+			base: "timestamp with time zone",
+		},
+		{
+			name: "interval fields",
+			typeName: &pg_query.TypeName{
+				Names:   []*pg_query.Node{strNode("pg_catalog"), strNode("interval")},
+				Typmods: []*pg_query.Node{intNode(1<<3 | 1<<10 | 1<<11 | 1<<12), intNode(3)},
+			},
+			typ:  "interval day to second(3)",
+			base: "interval",
+		},
+		{
+			name: "array of numeric",
+			typeName: &pg_query.TypeName{
+				Names:       []*pg_query.Node{strNode("pg_catalog"), strNode("numeric")},
+				Typmods:     []*pg_query.Node{intNode(10), intNode(2)},
+				ArrayBounds: []*pg_query.Node{arrayBound(-1), arrayBound(-1)},
+			},
+			typ:  "numeric(10,2)[][]",
+			base: "numeric",
+			dims: 2,
+		},
+		{
+			name: "sized array",
+			typeName: &pg_query.TypeName{
+				Names:       []*pg_query.Node{strNode("text")},
+				ArrayBounds: []*pg_query.Node{arrayBound(3)},
+			},
+			typ:  "text[3]",
+			base: "text",
+			dims: 1,
+		},
+		{
+			name:     "user-defined type",
+			typeName: &pg_query.TypeName{Names: []*pg_query.Node{strNode("billing"), strNode("status")}},
+			typ:      "billing.status",
+			base:     "billing.status",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, base, dims := formatTypeName(tt.typeName)
+			if typ != tt.typ || base != tt.base || dims != tt.dims {
+				t.Errorf("formatTypeName() = %q, %q, %d, want %q, %q, %d", typ, base, dims, tt.typ, tt.base, tt.dims)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package schema
+
+import (
+	"fmt"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// QualifiedName returns the domain name as "schema.name".
+func (d DomainDef) QualifiedName() string {
+	return QualifiedName(d.Schema, d.Name)
+}
+
+// QualifiedName returns the composite type name as "schema.name".
+func (c CompositeTypeDef) QualifiedName() string {
+	return QualifiedName(c.Schema, c.Name)
+}
+
+// QualifiedName returns the range type name as "schema.name".
+func (r RangeTypeDef) QualifiedName() string {
+	return QualifiedName(r.Schema, r.Name)
+}
+
+// Domain returns the domain with the given qualified name, or nil if the
+// schema has no such domain.
+func (s *Schema) Domain(name string) *DomainDef {
+	for i := range s.Domains {
+		if s.Domains[i].QualifiedName() == name {
+			return &s.Domains[i]
+		}
+	}
+	return nil
+}
+
+// CompositeType returns the composite type with the given qualified name,
+// or nil if the schema has no such type.
+func (s *Schema) CompositeType(name string) *CompositeTypeDef {
+	for i := range s.CompositeTypes {
+		if s.CompositeTypes[i].QualifiedName() == name {
+			return &s.CompositeTypes[i]
+		}
+	}
+	return nil
+}
+
+// RangeType returns the range type with the given qualified name, or nil
+// if the schema has no such type.
+func (s *Schema) RangeType(name string) *RangeTypeDef {
+	for i := range s.RangeTypes {
+		if s.RangeTypes[i].QualifiedName() == name {
+			return &s.RangeTypes[i]
+		}
+	}
+	return nil
+}
+
+// typeDependencies returns the user-defined types the type with the given
+// qualified name is built on: the base type of a domain, the attribute
+// types of a composite type and the subtype of a range type.
+func (s *Schema) typeDependencies(name string) []string {
+	var deps []string
+	if domain := s.Domain(name); domain != nil && domain.UserType != "" {
+		deps = append(deps, domain.UserType)
+	}
+	if typ := s.CompositeType(name); typ != nil {
+		for _, attr := range typ.Attributes {
+			if attr.UserType != "" {
+				deps = appendUnique(deps, attr.UserType)
+			}
+		}
+	}
+	if rng := s.RangeType(name); rng != nil && rng.UserType != "" {
+		deps = append(deps, rng.UserType)
+	}
+	return deps
+}
+
+// usedTypes returns the qualified names of the user-defined types the
+// columns of tables and the functions use, along with the types those are
+// built on, in the order they are first used.
+func (s *Schema) usedTypes(tables []TableDef, functions []FunctionDef) []string {
+	var used []string
+	var add func(name string)
+	add = func(name string) {
+		if contains(used, name) {
+			return
+		}
+		used = append(used, name)
+		for _, dep := range s.typeDependencies(name) {
+			add(dep)
+		}
+	}
+	for _, table := range tables {
+		for _, col := range table.Columns {
+			if col.UserType != "" {
+				add(col.UserType)
+			}
+		}
+	}
+	for _, fn := range functions {
+		for _, name := range fn.Types {
+			add(name)
+		}
+	}
+	return used
+}
+
+// UsedDomains returns the domains used by the columns of tables or by
+// functions, directly or through another user-defined type, in the order
+// they are first used.
+func (s *Schema) UsedDomains(tables []TableDef, functions []FunctionDef) []DomainDef {
+	var domains []DomainDef
+	for _, name := range s.usedTypes(tables, functions) {
+		if domain := s.Domain(name); domain != nil {
+			domains = append(domains, *domain)
+		}
+	}
+	return domains
+}
+
+// UsedCompositeTypes returns the composite types used by the columns of
+// tables or by functions, directly or through another user-defined type,
+// in the order they are first used.
+func (s *Schema) UsedCompositeTypes(tables []TableDef, functions []FunctionDef) []CompositeTypeDef {
+	var types []CompositeTypeDef
+	for _, name := range s.usedTypes(tables, functions) {
+		if typ := s.CompositeType(name); typ != nil {
+			types = append(types, *typ)
+		}
+	}
+	return types
+}
+
+// UsedRangeTypes returns the range types used by the columns of tables or
+// by functions, directly or through another user-defined type, in the
+// order they are first used.
+func (s *Schema) UsedRangeTypes(tables []TableDef, functions []FunctionDef) []RangeTypeDef {
+	var ranges []RangeTypeDef
+	for _, name := range s.usedTypes(tables, functions) {
+		if rng := s.RangeType(name); rng != nil {
+			ranges = append(ranges, *rng)
+		}
+	}
+	return ranges
+}
+
+// ParseCreateDomain converts a CREATE DOMAIN statement into a DomainDef.
+// CHECK constraints are deparsed back into SQL. The SQL field is left
+// empty.
+func ParseCreateDomain(stmt *pg_query.CreateDomainStmt) (DomainDef, error) {
+	domain := DomainDef{
+		Stmt: &pg_query.Node{Node: &pg_query.Node_CreateDomainStmt{CreateDomainStmt: stmt}},
+	}
+	domain.Schema, domain.Name = splitTypeName(stringList(stmt.GetDomainname()))
+	domain.Type, domain.BaseType, _ = formatTypeName(stmt.GetTypeName())
+
+	for _, node := range stmt.GetConstraints() {
+		constraint := node.GetConstraint()
+		switch constraint.GetContype() {
+		case pg_query.ConstrType_CONSTR_NOTNULL:
+			domain.NotNull = true
+		case pg_query.ConstrType_CONSTR_DEFAULT:
+			domain.Default = defaultValue(constraint.GetRawExpr())
+			domain.addFunctions(constraint.GetRawExpr())
+		case pg_query.ConstrType_CONSTR_CHECK:
+			domain.addFunctions(constraint.GetRawExpr())
+			expr, err := DeparseExpr(constraint.GetRawExpr())
+			if err != nil {
+				return domain, fmt.Errorf("error deparsing check of domain %s: %v", domain.QualifiedName(), err)
+			}
+			check := fmt.Sprintf("CHECK (%s)", expr)
+			if name := constraint.GetConname(); name != "" {
+				check = fmt.Sprintf("CONSTRAINT %s %s", QuoteIdent(name), check)
+			}
+			domain.Checks = append(domain.Checks, check)
+		}
+	}
+	return domain, nil
+}
+
+// addFunctions adds the functions expr calls to Functions.
+func (d *DomainDef) addFunctions(expr *pg_query.Node) {
+	functions, _ := expressionDependencies(expr)
+	for _, function := range functions {
+		d.Functions = appendUnique(d.Functions, function)
+	}
+}
+
+// ParseCompositeType converts a CREATE TYPE ... AS (...) statement into a
+// CompositeTypeDef. The SQL field is left empty.
+func ParseCompositeType(stmt *pg_query.CompositeTypeStmt) CompositeTypeDef {
+	typ := CompositeTypeDef{
+		Name:   stmt.GetTypevar().GetRelname(),
+		Schema: DefaultSchema,
+		Stmt:   &pg_query.Node{Node: &pg_query.Node_CompositeTypeStmt{CompositeTypeStmt: stmt}},
+	}
+	if schema := stmt.GetTypevar().GetSchemaname(); schema != "" {
+		typ.Schema = schema
+	}
+	for _, node := range stmt.GetColdeflist() {
+		if def := node.GetColumnDef(); def != nil {
+			attr := ColumnDef{Name: def.GetColname()}
+			attr.Type, attr.BaseType, attr.ArrayDims = formatTypeName(def.GetTypeName())
+			typ.Attributes = append(typ.Attributes, attr)
+		}
+	}
+	return typ
+}
+
+// ParseCreateRange converts a CREATE TYPE ... AS RANGE statement into a
+// RangeTypeDef. The SQL field is left empty.
+func ParseCreateRange(stmt *pg_query.CreateRangeStmt) RangeTypeDef {
+	rng := RangeTypeDef{
+		Stmt: &pg_query.Node{Node: &pg_query.Node_CreateRangeStmt{CreateRangeStmt: stmt}},
+	}
+	rng.Schema, rng.Name = splitTypeName(stringList(stmt.GetTypeName()))
+	for _, param := range stmt.GetParams() {
+		if defElem := param.GetDefElem(); defElem.GetDefname() == "subtype" {
+			rng.Subtype, _, _ = formatTypeName(defElem.GetArg().GetTypeName())
+		}
+	}
+	return rng
+}
+
+// splitTypeName returns the schema and name of a type given by its name
+// list, defaulting to the public schema.
+func splitTypeName(names []string) (schema, name string) {
+	switch len(names) {
+	case 0:
+		return DefaultSchema, ""
+	case 1:
+		return DefaultSchema, names[0]
+	}
+	return names[len(names)-2], names[len(names)-1]
+}
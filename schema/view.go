@@ -0,0 +1,280 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+// systemSchemas are the schemas of the catalog, whose relations a view may
+// read but a dump never creates.
+var systemSchemas = map[string]bool{
+	"pg_catalog":         true,
+	"information_schema": true,
+}
+
+// QualifiedName returns the view name as "schema.name".
+func (v ViewDef) QualifiedName() string {
+	return QualifiedName(v.Schema, v.Name)
+}
+
+// View returns the view or materialized view with the given qualified
+// name, or nil if the schema has no such view.
+func (s *Schema) View(name string) *ViewDef {
+	for i := range s.Views {
+		if s.Views[i].QualifiedName() == name {
+			return &s.Views[i]
+		}
+	}
+	return nil
+}
+
+// ViewsOver returns the views whose queries only read from tables and
+// from other views so returned, and only use columns those tables have, in
+// the order s defines them. These are the views that can be created in a
+// database holding nothing but tables.
+func (s *Schema) ViewsOver(tables []TableDef) []ViewDef {
+	available := make(map[string]*TableDef)
+	for i := range tables {
+		available[tables[i].QualifiedName()] = &tables[i]
+	}
+	included := make(map[string]bool)
+
+	// A view may come before a view it reads from, so repeat until no
+	// more views can be added
+	for added := true; added; {
+		added = false
+		for _, view := range s.Views {
+			if !included[view.QualifiedName()] && view.readsOnly(available, included) {
+				included[view.QualifiedName()] = true
+				added = true
+			}
+		}
+	}
+
+	var views []ViewDef
+	for _, view := range s.Views {
+		if included[view.QualifiedName()] {
+			views = append(views, view)
+		}
+	}
+	return views
+}
+
+// readsOnly reports whether every relation v reads from is one of tables
+// or views, and every column it uses exists in its table.
+func (v ViewDef) readsOnly(tables map[string]*TableDef, views map[string]bool) bool {
+	for _, relation := range v.Relations {
+		if tables[relation] == nil && !views[relation] {
+			return false
+		}
+	}
+	for _, column := range v.Columns {
+		i := strings.LastIndex(column, ".")
+		if table := tables[column[:i]]; table != nil && table.Column(column[i+1:]) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCreateView converts a CREATE VIEW statement into a ViewDef. The SQL
+// field and the view's references are left empty.
+func ParseCreateView(stmt *pg_query.ViewStmt) ViewDef {
+	view := ViewDef{
+		Name:   stmt.GetView().GetRelname(),
+		Schema: DefaultSchema,
+		Stmt:   &pg_query.Node{Node: &pg_query.Node_ViewStmt{ViewStmt: stmt}},
+	}
+	if schema := stmt.GetView().GetSchemaname(); schema != "" {
+		view.Schema = schema
+	}
+	return view
+}
+
+// ParseCreateMaterializedView converts a CREATE MATERIALIZED VIEW
+// statement into a ViewDef. The SQL field and the view's references are
+// left empty.
+func ParseCreateMaterializedView(stmt *pg_query.CreateTableAsStmt) ViewDef {
+	view := ViewDef{
+		Name:         stmt.GetInto().GetRel().GetRelname(),
+		Schema:       DefaultSchema,
+		Materialized: true,
+		Stmt:         &pg_query.Node{Node: &pg_query.Node_CreateTableAsStmt{CreateTableAsStmt: stmt}},
+	}
+	if schema := stmt.GetInto().GetRel().GetSchemaname(); schema != "" {
+		view.Schema = schema
+	}
+	return view
+}
+
+// findViewReferences fills in the relations, columns and functions the
+// query of view uses. Relation names are resolved along the view's search
+// path among the tables and views s defined before it, as Postgres does
+// when it creates the view.
+//
+// Column references are matched to relations by alias or table name
+// across the whole query, so a name reused in a subquery for another
+// relation may be attributed to either of them. Unqualified columns go to
+// the first table that has a column of that name.
+func (s *Schema) findViewReferences(view *ViewDef) {
+	query := view.Stmt.GetViewStmt().GetQuery()
+	if query == nil {
+		query = view.Stmt.GetCreateTableAsStmt().GetQuery()
+	}
+
+	ctes := make(map[string]bool)
+	var relations []*pg_query.RangeVar
+	var columnRefs [][]string
+	walk(query, func(node *pg_query.Node) bool {
+		switch {
+		case node.GetCommonTableExpr() != nil:
+			ctes[node.GetCommonTableExpr().GetCtename()] = true
+		case node.GetRangeVar() != nil:
+			relations = append(relations, node.GetRangeVar())
+		case node.GetColumnRef() != nil:
+			// a.* names no particular column
+			fields := node.GetColumnRef().GetFields()
+			if len(fields) > 0 && fields[len(fields)-1].GetAStar() == nil {
+				columnRefs = append(columnRefs, stringList(fields))
+			}
+		case node.GetFuncCall() != nil:
+			view.Functions = appendUnique(view.Functions, strings.Join(stringList(node.GetFuncCall().GetFuncname()), "."))
+		}
+		return true
+	})
+
+	// CTEs can only be told apart from relations once the whole query has
+	// been walked, since the WITH clause may come after the FROM clause
+	aliases := make(map[string]string)
+	for _, rv := range relations {
+		if rv.GetSchemaname() == "" && ctes[rv.GetRelname()] {
+			continue
+		}
+		name := s.resolveRelation(rv.GetSchemaname(), rv.GetRelname(), view.SearchPath)
+		if name == "" {
+			continue
+		}
+		view.Relations = appendUnique(view.Relations, name)
+		alias := rv.GetRelname()
+		if rv.GetAlias() != nil {
+			alias = rv.GetAlias().GetAliasname()
+		}
+		aliases[alias] = name
+	}
+	for _, fields := range columnRefs {
+		view.addColumn(s, aliases, fields)
+	}
+}
+
+// addColumn records the column a column reference of the view's query,
+// given by its name parts, points at, if it can be found.
+func (v *ViewDef) addColumn(s *Schema, aliases map[string]string, fields []string) {
+	column := fields[len(fields)-1]
+	relation := ""
+	switch len(fields) {
+	case 1:
+		for _, name := range v.Relations {
+			if table := s.Table(name); table != nil && table.Column(column) != nil {
+				relation = name
+				break
+			}
+		}
+	case 2:
+		relation = aliases[fields[0]]
+	case 3:
+		if name := QualifiedName(fields[0], fields[1]); contains(v.Relations, name) {
+			relation = name
+		}
+	}
+	if relation != "" {
+		v.Columns = appendUnique(v.Columns, relation+"."+column)
+	}
+}
+
+// resolveRelation returns the qualified name of the table or view a
+// relation name of a query refers to along searchPath. Unqualified names
+// that match nothing s defines are taken to be in the first schema of the
+// path, except for catalog relations, for which "" is returned.
+func (s *Schema) resolveRelation(schema, name string, searchPath []string) string {
+	if systemSchemas[schema] {
+		return ""
+	}
+	if schema != "" {
+		return QualifiedName(schema, name)
+	}
+	for _, schema := range searchPath {
+		if qualified := QualifiedName(schema, name); s.Table(qualified) != nil || s.View(qualified) != nil {
+			return qualified
+		}
+	}
+	if strings.HasPrefix(name, "pg_") {
+		return ""
+	}
+	if len(searchPath) == 0 {
+		return QualifiedName(DefaultSchema, name)
+	}
+	return QualifiedName(searchPath[0], name)
+}
+
+var (
+	viewPattern          = regexp.MustCompile(`^CREATE (MATERIALIZED )?VIEW "?([a-zA-Z0-9_]+)"?\."?([a-zA-Z0-9_]+)"?`)
+	qualifiedNamePattern = regexp.MustCompile(`"?([a-zA-Z_][a-zA-Z0-9_]*)"?\."?([a-zA-Z_][a-zA-Z0-9_]*)"?`)
+)
+
+// parseViewsRegex adds the CREATE VIEW and CREATE MATERIALIZED VIEW blocks
+// pg_dump writes to s.Views. pg_dump qualifies every relation a view reads
+// from, so the relations are the schema-qualified names in the query that
+// s defines, and the columns those written as table.column. Functions and
+// columns referenced through an alias are not found.
+func (s *Schema) parseViewsRegex(sqlContent string) {
+	var current *ViewDef
+	searchPath := DefaultSearchPath
+	for i, line := range strings.Split(sqlContent, "\n") {
+		if current == nil {
+			if path, ok := searchPathRegex(line); ok {
+				searchPath = path
+			}
+			if matches := viewPattern.FindStringSubmatch(line); matches != nil {
+				current = &ViewDef{
+					Schema:       matches[2],
+					Name:         matches[3],
+					Materialized: matches[1] != "",
+					SearchPath:   searchPath,
+					Pos:          Position{Line: i + 1, Column: 1},
+				}
+			}
+		}
+		if current == nil {
+			continue
+		}
+
+		current.SQL += line + "\n"
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			s.findViewReferencesRegex(current)
+			s.Views = append(s.Views, *current)
+			current = nil
+		}
+	}
+}
+
+// findViewReferencesRegex fills in the relations and columns the query in
+// view.SQL names.
+func (s *Schema) findViewReferencesRegex(view *ViewDef) {
+	code := stringLiteralPattern.ReplaceAllString(view.SQL, "''")
+	for _, matches := range qualifiedNamePattern.FindAllStringSubmatch(code, -1) {
+		name := QualifiedName(matches[1], matches[2])
+		if s.Table(name) != nil || s.View(name) != nil {
+			view.Relations = appendUnique(view.Relations, name)
+		}
+	}
+	for _, matches := range qualifiedNamePattern.FindAllStringSubmatch(code, -1) {
+		for _, relation := range view.Relations {
+			if relation[strings.Index(relation, ".")+1:] == matches[1] {
+				view.Columns = appendUnique(view.Columns, relation+"."+matches[2])
+				break
+			}
+		}
+	}
+}
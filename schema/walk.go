@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"reflect"
+
+	pg_query "github.com/pganalyze/pg_query_go/v4"
+)
+
+var (
+	nodeType     = reflect.TypeOf((*pg_query.Node)(nil))
+	rangeVarType = reflect.TypeOf((*pg_query.RangeVar)(nil))
+)
+
+// walk calls visit for node and every node below it, parents before their
+// children. When visit returns false the children of that node are
+// skipped.
+//
+// pg_query has no visitor of its own, so the tree is walked through the
+// exported fields of the generated structs. Some statements, such as
+// INSERT, UPDATE and DELETE, hold their target as a bare RangeVar rather
+// than in a Node, so those are visited wrapped in a Node of their own.
+func walk(node *pg_query.Node, visit func(*pg_query.Node) bool) {
+	walkValue(reflect.ValueOf(node), visit)
+}
+
+func walkValue(v reflect.Value, visit func(*pg_query.Node) bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		switch v.Type() {
+		case nodeType:
+			// A RangeVar has no nodes below it, and walking into it
+			// would visit it a second time as a bare RangeVar
+			node := v.Interface().(*pg_query.Node)
+			if !visit(node) || node.GetRangeVar() != nil {
+				return
+			}
+		case rangeVarType:
+			visit(&pg_query.Node{Node: &pg_query.Node_RangeVar{RangeVar: v.Interface().(*pg_query.RangeVar)}})
+			return
+		}
+		walkValue(v.Elem(), visit)
+	case reflect.Interface:
+		if !v.IsNil() {
+			walkValue(v.Elem(), visit)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkValue(v.Field(i), visit)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkValue(v.Index(i), visit)
+		}
+	}
+}
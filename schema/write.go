@@ -0,0 +1,350 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Sorted returns a copy of s with every kind of object in creation order:
+// objects come after the objects they depend on, and are otherwise sorted
+// by qualified name.
+func (s *Schema) Sorted() *Schema {
+	sorted := &Schema{
+		Extensions:     append([]ExtensionDef(nil), s.Extensions...),
+		Enums:          append([]EnumDef(nil), s.Enums...),
+		ForeignKeys:    append([]ForeignKeyDef(nil), s.ForeignKeys...),
+		Indexes:        append([]IndexDef(nil), s.Indexes...),
+		Sequences:      append([]SequenceDef(nil), s.Sequences...),
+		Domains:        append([]DomainDef(nil), s.Domains...),
+		CompositeTypes: append([]CompositeTypeDef(nil), s.CompositeTypes...),
+		RangeTypes:     append([]RangeTypeDef(nil), s.RangeTypes...),
+		Functions:      append([]FunctionDef(nil), s.Functions...),
+		Triggers:       append([]TriggerDef(nil), s.Triggers...),
+	}
+
+	sort.SliceStable(sorted.Extensions, func(i, j int) bool {
+		return sorted.Extensions[i].Name < sorted.Extensions[j].Name
+	})
+	sort.SliceStable(sorted.Enums, func(i, j int) bool {
+		return sorted.Enums[i].QualifiedName() < sorted.Enums[j].QualifiedName()
+	})
+	sort.SliceStable(sorted.Sequences, func(i, j int) bool {
+		return sorted.Sequences[i].QualifiedName() < sorted.Sequences[j].QualifiedName()
+	})
+	sort.SliceStable(sorted.Domains, func(i, j int) bool {
+		return sorted.Domains[i].QualifiedName() < sorted.Domains[j].QualifiedName()
+	})
+	sort.SliceStable(sorted.CompositeTypes, func(i, j int) bool {
+		return sorted.CompositeTypes[i].QualifiedName() < sorted.CompositeTypes[j].QualifiedName()
+	})
+	sort.SliceStable(sorted.RangeTypes, func(i, j int) bool {
+		return sorted.RangeTypes[i].QualifiedName() < sorted.RangeTypes[j].QualifiedName()
+	})
+	sort.SliceStable(sorted.Functions, func(i, j int) bool {
+		return sorted.Functions[i].Signature() < sorted.Functions[j].Signature()
+	})
+
+	// Parent tables must exist before the tables that inherit from them
+	tables := make(map[string]TableDef)
+	var names []string
+	for _, table := range s.Tables {
+		tables[table.QualifiedName()] = table
+		names = append(names, table.QualifiedName())
+	}
+	for _, name := range sortByDependencies(names, func(name string) []string {
+		return tables[name].Inherits
+	}) {
+		sorted.Tables = append(sorted.Tables, tables[name])
+	}
+
+	// Views must exist before the views that read from them
+	views := make(map[string]ViewDef)
+	names = nil
+	for _, view := range s.Views {
+		views[view.QualifiedName()] = view
+		names = append(names, view.QualifiedName())
+	}
+	for _, name := range sortByDependencies(names, func(name string) []string {
+		return views[name].Relations
+	}) {
+		sorted.Views = append(sorted.Views, views[name])
+	}
+
+	sort.SliceStable(sorted.ForeignKeys, func(i, j int) bool {
+		a, b := sorted.ForeignKeys[i], sorted.ForeignKeys[j]
+		if a.FromTable() != b.FromTable() {
+			return a.FromTable() < b.FromTable()
+		}
+		return a.Name < b.Name
+	})
+	sort.SliceStable(sorted.Indexes, func(i, j int) bool {
+		a, b := sorted.Indexes[i], sorted.Indexes[j]
+		if a.TableName() != b.TableName() {
+			return a.TableName() < b.TableName()
+		}
+		return a.Name < b.Name
+	})
+	sort.SliceStable(sorted.Triggers, func(i, j int) bool {
+		a, b := sorted.Triggers[i], sorted.Triggers[j]
+		if a.TableName() != b.TableName() {
+			return a.TableName() < b.TableName()
+		}
+		return a.Name < b.Name
+	})
+	return sorted
+}
+
+// sortByDependencies orders names so that each name comes after the names
+// deps returns for it, breaking ties alphabetically. Dependencies outside
+// names are ignored, and names caught in a cycle are appended
+// alphabetically once nothing else can be placed.
+func sortByDependencies(names []string, deps func(string) []string) []string {
+	pending := make(map[string]bool)
+	for _, name := range names {
+		pending[name] = true
+	}
+
+	remaining := append([]string(nil), names...)
+	sort.Strings(remaining)
+
+	var sorted []string
+	for len(remaining) > 0 {
+		placed := -1
+		for i, name := range remaining {
+			ready := true
+			for _, dep := range deps(name) {
+				if dep != name && pending[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed = i
+				break
+			}
+		}
+		if placed < 0 {
+			// Break the cycle with the first remaining name
+			placed = 0
+		}
+
+		name := remaining[placed]
+		sorted = append(sorted, name)
+		delete(pending, name)
+		remaining = append(remaining[:placed], remaining[placed+1:]...)
+	}
+	return sorted
+}
+
+// WriteSQL writes s to w as a script that can be loaded into an empty
+// database: extensions, then types, sequences, functions, tables, views,
+// indexes, triggers and finally foreign key constraints, each in the order
+// given by Sorted. Types come after the types they are built on. The column
+// defaults and constraints added to a table by ALTER TABLE follow its
+// CREATE TABLE statement, along with the OWNED BY statements of the
+// sequences its columns own.
+//
+// Function bodies are not checked when they are created, as in pg_dump's
+// output, so functions come before the tables their defaults, checks and
+// bodies use. Functions whose signature uses a table's row type, and
+// SQL-standard bodies, which are checked, come after the tables instead.
+//
+// Statements are written as the dump has them, so names they leave
+// unqualified have to resolve as they did in the dump. Before a statement
+// whose object was created under another search_path than the one in
+// effect, the script sets it with set_config, as pg_dump does.
+func (s *Schema) WriteSQL(w io.Writer) error {
+	sorted := s.Sorted()
+
+	sections := []struct {
+		title      string
+		statements []scriptStatement
+	}{
+		{"Extensions", nil},
+		{"Types", nil},
+		{"Sequences", nil},
+		{"Functions", nil},
+		{"Types using functions", nil},
+		{"Tables", nil},
+		{"Functions using tables", nil},
+		{"Views", nil},
+		{"Indexes", nil},
+		{"Triggers", nil},
+		{"Foreign key constraints", nil},
+	}
+	add := func(section int, sql string, searchPath []string) {
+		sections[section].statements = append(sections[section].statements, scriptStatement{sql, searchPath})
+	}
+	for _, ext := range sorted.Extensions {
+		add(0, ext.SQL, nil)
+	}
+	sections[1].statements, sections[4].statements = s.typeStatements()
+	for _, seq := range sorted.Sequences {
+		add(2, seq.SQL, seq.SearchPath)
+	}
+	for _, fn := range sorted.Functions {
+		if s.usesTables(fn) {
+			add(6, fn.SQL, fn.DumpSearchPath)
+		} else {
+			add(3, fn.SQL, fn.DumpSearchPath)
+		}
+	}
+	for _, i := range []int{3, 6} {
+		if len(sections[i].statements) > 0 {
+			sections[i].statements = append([]scriptStatement{{sql: "SET check_function_bodies = false;"}}, sections[i].statements...)
+		}
+	}
+	for _, table := range sorted.Tables {
+		add(5, table.SQL, table.SearchPath)
+		for _, alteration := range table.ColumnAlterations {
+			add(5, alteration.SQL, table.SearchPath)
+		}
+		for _, c := range table.AddedConstraints {
+			add(5, c.SQL, table.SearchPath)
+		}
+		// A sequence can only be owned by a column that exists
+		for _, seq := range sorted.Sequences {
+			if seq.Ownership.SQL != "" && seq.OwnerTable() == table.QualifiedName() {
+				add(5, seq.Ownership.SQL, seq.SearchPath)
+			}
+		}
+	}
+	for _, view := range sorted.Views {
+		add(7, view.SQL, view.SearchPath)
+	}
+	for _, index := range sorted.Indexes {
+		add(8, index.SQL, index.SearchPath)
+	}
+	for _, trigger := range sorted.Triggers {
+		add(9, trigger.SQL, trigger.SearchPath)
+	}
+	for _, fk := range sorted.ForeignKeys {
+		add(10, fk.SQL, fk.SearchPath)
+	}
+
+	searchPath := DefaultSearchPath
+	for _, section := range sections {
+		if len(section.statements) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "-- %s\n\n", section.title); err != nil {
+			return err
+		}
+		for _, statement := range section.statements {
+			if statement.searchPath != nil && !equalPaths(statement.searchPath, searchPath) {
+				searchPath = statement.searchPath
+				if _, err := fmt.Fprintf(w, "%s\n\n", setSearchPathSQL(searchPath)); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s\n\n", strings.TrimRight(statement.sql, "\n")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scriptStatement is a statement WriteSQL writes, along with the
+// search_path it was parsed under, or nil if that is not known.
+type scriptStatement struct {
+	sql        string
+	searchPath []string
+}
+
+// setSearchPathSQL returns the statement pg_dump uses to set the
+// search_path to path.
+func setSearchPathSQL(path []string) string {
+	var schemas []string
+	for _, schema := range path {
+		schemas = append(schemas, QuoteIdent(schema))
+	}
+	return fmt.Sprintf("SELECT pg_catalog.set_config('search_path', '%s', false);",
+		strings.ReplaceAll(strings.Join(schemas, ", "), "'", "''"))
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// typeStatements returns the statements that create the user-defined types
+// of s, each after the types it is built on and otherwise sorted by
+// qualified name. The domains whose default or checks call a function s
+// defines, and the types built on them, are returned separately in
+// usingFunctions, to be created once the functions exist.
+func (s *Schema) typeStatements() (statements, usingFunctions []scriptStatement) {
+	types := make(map[string]scriptStatement)
+	for _, enum := range s.Enums {
+		types[enum.QualifiedName()] = scriptStatement{enum.SQL, enum.SearchPath}
+	}
+	for _, domain := range s.Domains {
+		types[domain.QualifiedName()] = scriptStatement{domain.SQL, domain.SearchPath}
+	}
+	for _, typ := range s.CompositeTypes {
+		types[typ.QualifiedName()] = scriptStatement{typ.SQL, typ.SearchPath}
+	}
+	for _, rng := range s.RangeTypes {
+		types[rng.QualifiedName()] = scriptStatement{rng.SQL, rng.SearchPath}
+	}
+
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	for _, name := range sortByDependencies(names, s.typeDependencies) {
+		if s.typeUsesFunctions(name, make(map[string]bool)) {
+			usingFunctions = append(usingFunctions, types[name])
+		} else {
+			statements = append(statements, types[name])
+		}
+	}
+	return statements, usingFunctions
+}
+
+// typeUsesFunctions reports whether the type with the given qualified name
+// is a domain whose default or checks call a function s defines, or is
+// built on one.
+func (s *Schema) typeUsesFunctions(name string, seen map[string]bool) bool {
+	if seen[name] {
+		return false
+	}
+	seen[name] = true
+	if domain := s.Domain(name); domain != nil {
+		for _, function := range domain.Functions {
+			if len(s.FunctionsNamed(function, domain.SearchPath)) > 0 {
+				return true
+			}
+		}
+	}
+	for _, dep := range s.typeDependencies(name) {
+		if s.typeUsesFunctions(dep, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesTables reports whether creating fn needs the tables of s to exist:
+// its arguments or result use a table's row type, or its body is written
+// in SQL-standard form, which is checked when the function is created.
+func (s *Schema) usesTables(fn FunctionDef) bool {
+	if fn.Body == "" && strings.EqualFold(fn.Language, "sql") {
+		return true
+	}
+	for _, name := range fn.Types {
+		if s.Table(name) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteSQLSearchPath(t *testing.T) {
+	const dump = `CREATE TYPE billing.status AS ENUM (
+    'open',
+    'paid'
+);
+
+CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+SET search_path = billing, public;
+
+CREATE TABLE invoices (
+    id bigint NOT NULL,
+    state status
+);
+`
+	s, err := ParseRegex(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("ParseRegex() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := s.WriteSQL(&out); err != nil {
+		t.Fatalf("WriteSQL() error = %v", err)
+	}
+	sql := out.String()
+	if n := strings.Count(sql, "set_config('search_path', 'billing, public', false)"); n != 1 {
+		t.Errorf("WriteSQL() sets the billing search_path %d times, want 1:\n%s", n, sql)
+	}
+
+	written, err := ParseRegex(strings.NewReader(sql))
+	if err != nil {
+		t.Fatalf("ParseRegex() of the written script error = %v", err)
+	}
+	table := written.Table("billing.invoices")
+	if table == nil {
+		t.Fatalf("billing.invoices not found in the written script:\n%s", sql)
+	}
+	if got := table.Columns[1].UserType; got != "billing.status" {
+		t.Errorf("state UserType = %q, want billing.status", got)
+	}
+	if written.Table("public.users") == nil {
+		t.Errorf("public.users not found in the written script:\n%s", sql)
+	}
+}
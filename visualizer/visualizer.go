@@ -0,0 +1,197 @@
+// Package visualizer converts a parsed schema into the SchemaData JSON
+// document the schema-visualizer app renders. The types mirror the
+// SchemaData interface in schema-visualizer/src/utils/sqlParser.ts.
+package visualizer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/sent-hil/pg_struct_parser/schema"
+)
+
+// SchemaData is the document the visualizer loads.
+type SchemaData struct {
+	Tables      []Table      `json:"tables"`
+	ForeignKeys []ForeignKey `json:"foreignKeys"`
+	Types       []Type       `json:"types"`
+}
+
+// Table is a single table node.
+type Table struct {
+	Name    string   `json:"name"`
+	Schema  string   `json:"schema"`
+	Columns []Column `json:"columns"`
+}
+
+// Column is a row of a table node.
+type Column struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	BaseType     string     `json:"baseType,omitempty"`
+	ArrayDims    int        `json:"arrayDims,omitempty"`
+	UserType     string     `json:"userType,omitempty"`
+	IsNotNull    bool       `json:"isNotNull"`
+	Default      string     `json:"default,omitempty"`
+	IsPrimaryKey bool       `json:"isPrimaryKey"`
+	IsForeignKey bool       `json:"isForeignKey"`
+	References   *Reference `json:"references,omitempty"`
+	EnumValues   []string   `json:"enumValues,omitempty"`
+}
+
+// Type is a user-defined type. Kind is "enum", "domain", "composite" or
+// "range", and only the fields of that kind are set.
+type Type struct {
+	Name       string      `json:"name"`
+	Schema     string      `json:"schema"`
+	Kind       string      `json:"kind"`
+	Values     []string    `json:"values,omitempty"`
+	BaseType   string      `json:"baseType,omitempty"`
+	IsNotNull  bool        `json:"isNotNull,omitempty"`
+	Default    string      `json:"default,omitempty"`
+	Checks     []string    `json:"checks,omitempty"`
+	Attributes []Attribute `json:"attributes,omitempty"`
+	Subtype    string      `json:"subtype,omitempty"`
+}
+
+// Attribute is a field of a composite type.
+type Attribute struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Reference is the column a foreign key column points at.
+type Reference struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// ForeignKey is an edge between two table nodes. The visualizer keys nodes
+// by bare table name, so schemas are not included. The remaining fields
+// describe the constraint the edge comes from.
+type ForeignKey struct {
+	FromTable         string `json:"fromTable"`
+	FromColumn        string `json:"fromColumn"`
+	ToTable           string `json:"toTable"`
+	ToColumn          string `json:"toColumn"`
+	Name              string `json:"name,omitempty"`
+	OnDelete          string `json:"onDelete,omitempty"`
+	OnUpdate          string `json:"onUpdate,omitempty"`
+	Match             string `json:"match,omitempty"`
+	Deferrable        bool   `json:"deferrable,omitempty"`
+	InitiallyDeferred bool   `json:"initiallyDeferred,omitempty"`
+	NotValid          bool   `json:"notValid,omitempty"`
+}
+
+// FromSchema builds the visualizer document for s. Tables, foreign keys and
+// types are listed in the order of Schema.Sorted, and multi-column foreign
+// keys become one edge per column pair.
+func FromSchema(s *schema.Schema) SchemaData {
+	s = s.Sorted()
+	data := SchemaData{
+		Tables:      []Table{},
+		ForeignKeys: []ForeignKey{},
+		Types:       []Type{},
+	}
+
+	for _, table := range s.Tables {
+		t := Table{
+			Name:    table.Name,
+			Schema:  table.Schema,
+			Columns: []Column{},
+		}
+		for _, col := range table.Columns {
+			c := Column{
+				Name:         col.Name,
+				Type:         col.Type,
+				BaseType:     col.BaseType,
+				ArrayDims:    col.ArrayDims,
+				UserType:     col.UserType,
+				IsNotNull:    col.IsNotNull,
+				Default:      col.Default,
+				IsPrimaryKey: table.IsPrimaryKey(col.Name),
+			}
+			if enum := s.ColumnEnum(col); enum != nil {
+				c.EnumValues = enum.Values
+			}
+			t.Columns = append(t.Columns, c)
+		}
+		data.Tables = append(data.Tables, t)
+	}
+
+	for _, fk := range s.ForeignKeys {
+		for i, column := range fk.Columns {
+			if i >= len(fk.RefColumns) {
+				break
+			}
+			edge := ForeignKey{
+				FromTable:         fk.Table,
+				FromColumn:        column,
+				ToTable:           fk.RefTable,
+				ToColumn:          fk.RefColumns[i],
+				Name:              fk.Name,
+				OnDelete:          fk.OnDelete,
+				OnUpdate:          fk.OnUpdate,
+				Match:             fk.Match,
+				Deferrable:        fk.Deferrable,
+				InitiallyDeferred: fk.InitiallyDeferred,
+				NotValid:          fk.NotValid,
+			}
+			data.ForeignKeys = append(data.ForeignKeys, edge)
+			markForeignKey(&data, fk.Schema, edge)
+		}
+	}
+
+	for _, enum := range s.Enums {
+		data.Types = append(data.Types, Type{Name: enum.Name, Schema: enum.Schema, Kind: "enum", Values: enum.Values})
+	}
+	for _, domain := range s.Domains {
+		data.Types = append(data.Types, Type{
+			Name:      domain.Name,
+			Schema:    domain.Schema,
+			Kind:      "domain",
+			BaseType:  domain.Type,
+			IsNotNull: domain.NotNull,
+			Default:   domain.Default,
+			Checks:    domain.Checks,
+		})
+	}
+	for _, typ := range s.CompositeTypes {
+		t := Type{Name: typ.Name, Schema: typ.Schema, Kind: "composite"}
+		for _, attr := range typ.Attributes {
+			t.Attributes = append(t.Attributes, Attribute{Name: attr.Name, Type: attr.Type})
+		}
+		data.Types = append(data.Types, t)
+	}
+	for _, rng := range s.RangeTypes {
+		data.Types = append(data.Types, Type{Name: rng.Name, Schema: rng.Schema, Kind: "range", Subtype: rng.Subtype})
+	}
+
+	return data
+}
+
+// markForeignKey flags the referencing column of edge in data.
+func markForeignKey(data *SchemaData, tableSchema string, edge ForeignKey) {
+	for i := range data.Tables {
+		table := &data.Tables[i]
+		if table.Schema != tableSchema || table.Name != edge.FromTable {
+			continue
+		}
+		for j := range table.Columns {
+			if table.Columns[j].Name == edge.FromColumn {
+				table.Columns[j].IsForeignKey = true
+				table.Columns[j].References = &Reference{
+					Table:  edge.ToTable,
+					Column: edge.ToColumn,
+				}
+			}
+		}
+	}
+}
+
+// WriteJSON writes the visualizer document for s to w.
+func WriteJSON(w io.Writer, s *schema.Schema) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(FromSchema(s))
+}